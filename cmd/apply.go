@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/mrinjamul/flareship/internal/backup"
+	"github.com/mrinjamul/flareship/internal/log"
+	"github.com/mrinjamul/flareship/pkg/plan"
+	"github.com/mrinjamul/flareship/pkg/schema"
+	"github.com/spf13/cobra"
+)
+
+// applyCmd applies a plan saved by `flareship plan --out`
+var applyCmd = &cobra.Command{
+	Use:   "apply <plan-file>",
+	Short: "apply a saved plan",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		saved, err := plan.ReadFile(args[0])
+		if err != nil {
+			log.Fatal("Failed to read plan: %v", err)
+		}
+		log.Info("applying plan %s created at %s", args[0], saved.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+
+		// refuse to apply anything if any zone changed since the plan
+		domains := make([]schema.DomainConfig, len(saved.Zones))
+		remotes := make([][]schema.Record, len(saved.Zones))
+		for i, zone := range saved.Zones {
+			domain, ok := findDomain(zone.ZoneID)
+			if !ok {
+				log.Fatal("no configured domain for zone %s (%s)", zone.Domain, zone.ZoneID)
+			}
+			domains[i] = domain
+
+			log.Info("verifying remote state of %s ...", zone.Domain)
+			remote, err := newClient(domain).ReadAllRecords(cmd.Context(), zone.ZoneID, zone.Types)
+			if err != nil {
+				log.Fatal("fail to fetch remote DNS records: %v", err)
+			}
+			remotes[i] = remote
+			if err := zone.Verify(remote); err != nil {
+				var drift *plan.DriftError
+				if errors.As(err, &drift) {
+					for _, change := range drift.Changes {
+						log.Info("  %s", change)
+					}
+				}
+				log.Fatal("%v, run `flareship plan` again", err)
+			}
+			if err := checkSafety(zone.ChangeSet, len(remote)); err != nil {
+				log.Fatal("%s: %v", zone.Domain, err)
+			}
+		}
+
+		for i, zone := range saved.Zones {
+			log.Info("apply for %s ...", zone.Domain)
+			printChangeSet(zone.ChangeSet)
+			if !zone.ChangeSet.Empty() {
+				if _, err := takeSnapshot(domains[i], zone.Types, remotes[i]); err != nil {
+					log.Fatal("failed to snapshot the records of %s, no changes were applied: %v", zone.Domain, err)
+				}
+			}
+			if _, err := applyChangeSet(cmd.Context(), newClient(domains[i]), zone.ChangeSet); err != nil {
+				log.Fatal("apply failed for %s: %v", zone.Domain, err)
+			}
+			log.Info("apply completed for %s 🎉", zone.Domain)
+		}
+		if !snapshotAt.IsZero() {
+			log.Info("SNAPSHOT - %s, run `flareship rollback --last` to undo", backup.Stamp(snapshotAt))
+		}
+	},
+}
+
+func init() {
+	addSafetyFlags(applyCmd)
+	applyCmd.Flags().BoolVar(&flagNoSnapshot, "no-snapshot", false, "do not snapshot the remote records before changing them")
+	applyCmd.Flags().BoolVar(&flagAtomic, "atomic", false, "apply all changes of a domain in a single batch request")
+}
+
+// findDomain returns the configured domain of the zone
+func findDomain(zoneID string) (schema.DomainConfig, bool) {
+	for _, domain := range AppConfig.Domains {
+		if domain.ZoneID == zoneID {
+			return domain, true
+		}
+	}
+	return schema.DomainConfig{}, false
+}
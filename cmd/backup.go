@@ -1,19 +1,21 @@
 package main
 
 import (
-	"encoding/json"
-	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/mrinjamul/flareship/internal/cloudflare"
+	"github.com/mrinjamul/flareship/internal/backup"
 	"github.com/mrinjamul/flareship/internal/log"
-	"github.com/mrinjamul/flareship/internal/utils"
 	"github.com/mrinjamul/flareship/pkg/schema"
 	"github.com/spf13/cobra"
 )
 
 var (
-	flagDomain string
+	flagDomain       string
+	flagBackupOutput string
+	flagGzip         bool
+	flagKeep         int
 )
 
 // versionCmd represents the version command
@@ -21,72 +23,83 @@ var backupCmd = &cobra.Command{
 	Use:   "backup",
 	Short: "backup DNS records to file.",
 	Run: func(cmd *cobra.Command, args []string) {
+		if flagBackupOutput != "" && !backup.IsDir(flagBackupOutput) && flagDomain == "" && len(AppConfig.Domains) > 1 {
+			log.Fatal("%s is a file, use --domain or an output directory to back up %d domains", flagBackupOutput, len(AppConfig.Domains))
+		}
 		for _, domain := range AppConfig.Domains {
 			if flagDomain != "" {
 				if flagDomain != domain.Name {
 					continue
 				}
 			}
-			var records []schema.Records
-			var cfrecords []schema.Record
-
-			if len(EnabledRecordType) == 0 {
-				EnabledRecordType = domain.RecordTypes
-			} else {
-				EnabledRecordType = []string{"A", "AAAA", "CNAME", "TXT", "MX", "SRV"}
-			}
-
-			if flagTypes != "" {
-				if flagTypes == "all" {
-					EnabledRecordType = []string{"A", "AAAA", "CNAME", "TXT", "MX", "SRV"}
-				} else {
-
-					types := strings.Split(flagTypes, ",")
-					for i := range types {
-						types[i] = strings.ToUpper(strings.TrimSpace(types[i]))
-					}
-					EnabledRecordType = types
-				}
-			}
+			types := selectedTypes(domain)
 
 			log.Info("Backup started...")
-			cfrecords = cloudflare.ReadAllRecords(domain.ZoneID, domain.CFToken, EnabledRecordType)
-			for _, record := range cfrecords {
-				var r schema.Records
-				suffix := "." + domain.Name
-				record.Name = strings.TrimSuffix(record.Name, suffix)
-				r.Record = record
-				records = append(records, r)
+			cfrecords, err := newClient(domain).ReadAllRecords(cmd.Context(), domain.ZoneID, types)
+			if err != nil {
+				log.With(log.Fields{"domain": domain.Name, "error": err}).Error("fail to fetch remote DNS records of %s: %v", domain.Name, err)
+				continue
 			}
 			log.Info("Backing up to file...")
-			err := backupRecords(records, domain.Name)
+			path, err := backupRecords(domain, types, cfrecords, flagBackupOutput)
 			if err != nil {
-				log.Error("Failed to backup records: %v", err)
+				log.With(log.Fields{"domain": domain.Name, "error": err}).Error("Failed to backup records of %s: %v", domain.Name, err)
+				continue
+			}
+			log.With(log.Fields{"domain": domain.Name, "records": len(cfrecords), "path": path}).Info("%d record(s) backed up to %s", len(cfrecords), path)
+			if flagKeep > 0 {
+				removed, err := backup.Prune(filepath.Dir(path), domain.Name, flagKeep)
+				if err != nil {
+					log.Fatal("Failed to remove old backups: %v", err)
+				}
+				for _, old := range removed {
+					log.Info("removed old backup %s", old)
+				}
 			}
 		}
 		log.Info("Backup completed.")
 	},
 }
 
+// selectedTypes returns the record types of the --type flag, or the
+// record_type list of the domain, all types if it has none
+func selectedTypes(domain schema.DomainConfig) []string {
+	switch {
+	case flagTypes == "all", flagTypes == "" && len(domain.RecordTypes) == 0:
+		return schema.RecordTypes
+	case flagTypes == "":
+		return domain.RecordTypes
+	}
+	types := strings.Split(flagTypes, ",")
+	for i := range types {
+		types[i] = strings.ToUpper(strings.TrimSpace(types[i]))
+	}
+	return types
+}
+
 func init() {
 	backupCmd.Flags().StringVar(&flagDomain, "domain", "", "specify the domain name")
 	backupCmd.Flags().StringVarP(&flagTypes, "type", "t", "", "specify the types of records")
+	backupCmd.Flags().StringVarP(&flagBackupOutput, "output", "o", "", "backup file or directory, the current directory by default")
+	backupCmd.Flags().BoolVar(&flagGzip, "gzip", false, "compress the backup with gzip")
+	backupCmd.Flags().IntVar(&flagKeep, "keep", 0, "keep only the newest N backups of each domain in the backup directory, 0 keeps all")
 }
 
-func backupRecords(records []schema.Records, domainName string) error {
-	var configFile string
-
-	date := utils.NewDate()
-	num := utils.RandomNumber()
-	configFile = "dns_records_" + domainName + "_" + date + "_" + num + ".json"
-	log.Info(configFile)
-	data, err := json.Marshal(records)
-	if err != nil {
-		return err
+// backupRecords writes a backup of the records of the domain to output, a
+// file or a directory, and returns the path of the backup
+func backupRecords(domain schema.DomainConfig, types []string, records []schema.Record, output string) (string, error) {
+	b := &backup.Backup{
+		Version:          backup.Version,
+		CreatedAt:        time.Now().UTC(),
+		FlareshipVersion: Version,
+		Domain:           domain.Name,
+		ZoneID:           domain.ZoneID,
+		Types:            types,
+		Records:          records,
 	}
-	err = os.WriteFile(configFile, data, 0644)
-	if err != nil {
-		return err
+	path := backup.Path(output, domain.Name, b.CreatedAt, flagGzip)
+	if err := backup.WriteFile(path, b); err != nil {
+		return "", err
 	}
-	return nil
+	return path, nil
 }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mrinjamul/flareship/internal/cloudflare"
+	"github.com/mrinjamul/flareship/pkg/schema"
+)
+
+// limiters holds one rate limiter per api token, shared by all its domains
+var limiters = map[string]*cloudflare.RateLimiter{}
+
+// newClient returns a cloudflare api client for the given domain
+func newClient(domain schema.DomainConfig) *cloudflare.Client {
+	client := cloudflare.NewClient(domain.CFToken)
+	client.UserAgent = cloudflare.DefaultUserAgent + "/" + Version
+
+	rateLimit := cloudflare.DefaultRateLimit
+	rateLimitWindow := cloudflare.DefaultRateLimitWindow
+	if api := AppConfig.API; api != nil {
+		if api.BaseURL != "" {
+			client.BaseURL = withTrailingSlash(api.BaseURL)
+		}
+		// durations are checked by schema.APIConfig.Validate when loading the config
+		if api.MaxRetries != nil {
+			client.Retry.MaxRetries = *api.MaxRetries
+		}
+		if d, err := time.ParseDuration(api.RetryWaitMin); err == nil {
+			client.Retry.WaitMin = d
+		}
+		if d, err := time.ParseDuration(api.RetryWaitMax); err == nil {
+			client.Retry.WaitMax = d
+		}
+		if api.RateLimit != 0 {
+			rateLimit = api.RateLimit
+		}
+		if d, err := time.ParseDuration(api.RateLimitWindow); err == nil {
+			rateLimitWindow = d
+		}
+		if api.BatchLimit > 0 {
+			client.BatchLimit = api.BatchLimit
+		}
+	}
+
+	if baseURL := os.Getenv("FLARESHIP_API_BASE_URL"); baseURL != "" {
+		client.BaseURL = withTrailingSlash(baseURL)
+	}
+
+	limiter, ok := limiters[domain.CFToken]
+	if !ok {
+		limiter = cloudflare.NewRateLimiter(rateLimit, rateLimitWindow)
+		limiters[domain.CFToken] = limiter
+	}
+	client.Limiter = limiter
+	return client
+}
+
+// withTrailingSlash makes sure endpoints can be appended to the base url
+func withTrailingSlash(baseURL string) string {
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return baseURL
+}
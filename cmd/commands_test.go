@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mrinjamul/flareship/internal/backup"
+	"github.com/mrinjamul/flareship/internal/cloudflare/cloudflaretest"
+	"github.com/mrinjamul/flareship/internal/log"
+	"github.com/mrinjamul/flareship/pkg/schema"
+	"github.com/spf13/cobra"
+)
+
+const testZoneID = "z1"
+
+// setupCommand starts a fake api with an empty example.com zone and points
+// the config and the commands at it. The domain syncs the A records of the
+// records file, snapshots are written to a temporary directory.
+func setupCommand(t *testing.T) (*cloudflaretest.Server, string) {
+	t.Helper()
+	server := cloudflaretest.NewServer()
+	server.AddZone(testZoneID, "example.com")
+	t.Setenv("FLARESHIP_API_BASE_URL", server.Start())
+	t.Cleanup(server.Close)
+
+	recordFile := filepath.Join(t.TempDir(), "records.json")
+	AppConfig = &schema.AppConfig{Domains: []schema.DomainConfig{
+		{CFToken: "token", ZoneID: testZoneID, Name: "example.com", RecordFile: recordFile, RecordTypes: []string{"A"}},
+	}, Snapshots: &schema.SnapshotConfig{Dir: t.TempDir()}}
+	t.Cleanup(func() {
+		AppConfig = nil
+		flagDryRun, flagAtomic, flagYes, flagAdopt, flagDomain, flagTypes = false, false, false, false, "", ""
+		flagBackupOutput, flagGzip, flagKeep = "", false, 0
+	})
+	return server, recordFile
+}
+
+// writeRecords writes the records file, records are "name content" A records
+func writeRecords(t *testing.T, path string, records ...string) {
+	t.Helper()
+	entries := []schema.Records{}
+	for _, r := range records {
+		name, content, _ := strings.Cut(r, " ")
+		entries = append(entries, schema.Records{Record: schema.Record{Type: "A", Name: name, Content: content}})
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// execute runs the command with the arguments. Like a new process, every run
+// takes its own snapshots.
+func execute(t *testing.T, cmd *cobra.Command, args ...string) {
+	t.Helper()
+	snapshots, snapshotAt = nil, time.Time{}
+	cmd.SetArgs(args)
+	if err := cmd.ExecuteContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// remoteRecords returns the records of the zone as "name content" strings
+func remoteRecords(server *cloudflaretest.Server) []string {
+	records := []string{}
+	for _, r := range server.Records(testZoneID) {
+		records = append(records, r.Name+" "+r.Content)
+	}
+	return records
+}
+
+// writes returns the number of requests which change records
+func writes(server *cloudflaretest.Server) int {
+	var n int
+	for _, r := range server.Requests() {
+		if !strings.HasPrefix(r, "GET ") {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSyncCommand(t *testing.T) {
+	server, recordFile := setupCommand(t)
+
+	writeRecords(t, recordFile, "@ 1.1.1.1", "www 2.2.2.2", "old 3.3.3.3")
+	execute(t, syncCmd)
+	want := []string{"example.com 1.1.1.1", "old.example.com 3.3.3.3", "www.example.com 2.2.2.2"}
+	if got := remoteRecords(server); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got remote records %q after the first sync, want %q", got, want)
+	}
+
+	writeRecords(t, recordFile, "@ 1.1.1.1", "www 4.4.4.4")
+	execute(t, syncCmd, "--yes")
+	want = []string{"example.com 1.1.1.1", "www.example.com 4.4.4.4"}
+	if got := remoteRecords(server); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got remote records %q after the second sync, want %q", got, want)
+	}
+	// both syncs keep their snapshot, even within the same second
+	if files, _ := filepath.Glob(filepath.Join(AppConfig.Snapshots.Dir, "dns_records_example.com_*.json")); len(files) != 2 {
+		t.Fatalf("got snapshots %v, want one of each sync", files)
+	}
+
+	// the zone is in sync, nothing is written
+	n := writes(server)
+	execute(t, syncCmd)
+	if got := writes(server) - n; got != 0 {
+		t.Fatalf("got %d write requests for a zone in sync, want none", got)
+	}
+}
+
+func TestSyncCommandDryRun(t *testing.T) {
+	server, recordFile := setupCommand(t)
+	writeRecords(t, recordFile, "www 1.1.1.1")
+
+	execute(t, syncCmd, "--dry-run")
+	if n := writes(server); n != 0 {
+		t.Fatalf("got %d write requests for a dry run, want none", n)
+	}
+}
+
+func TestSyncCommandConflict(t *testing.T) {
+	server, recordFile := setupCommand(t)
+	server.Seed(testZoneID, schema.Record{Type: "A", Name: "www", Content: "9.9.9.9", TTL: schema.TTLAuto})
+	writeRecords(t, recordFile, "www 1.1.1.1", "www 2.2.2.2", "api 3.3.3.3")
+
+	// the other changes are applied, but the conflicting set fails the sync
+	errors := log.Errors()
+	execute(t, syncCmd)
+	want := []string{"api.example.com 3.3.3.3", "www.example.com 9.9.9.9"}
+	if got := remoteRecords(server); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got remote records %q, want %q", got, want)
+	}
+	if log.Errors() == errors {
+		t.Fatal("sync succeeded with a conflicting record set")
+	}
+
+	execute(t, syncCmd, "--adopt")
+	want = []string{"api.example.com 3.3.3.3", "www.example.com 1.1.1.1", "www.example.com 2.2.2.2"}
+	if got := remoteRecords(server); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got remote records %q after adopting, want %q", got, want)
+	}
+}
+
+func TestDiffCommand(t *testing.T) {
+	server, recordFile := setupCommand(t)
+	server.Seed(testZoneID, schema.Record{Type: "A", Name: "www", Content: "2.2.2.2", TTL: schema.TTLAuto})
+	writeRecords(t, recordFile, "www 1.1.1.1", "api 3.3.3.3")
+
+	execute(t, diffCmd)
+	if n := writes(server); n != 0 {
+		t.Fatalf("got %d write requests for a diff, want none", n)
+	}
+	if got, want := remoteRecords(server), []string{"www.example.com 2.2.2.2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got remote records %q after the diff, want %q", got, want)
+	}
+}
+
+func TestBackupCommand(t *testing.T) {
+	server, _ := setupCommand(t)
+	server.Seed(testZoneID,
+		schema.Record{Type: "A", Name: "www", Content: "1.1.1.1", TTL: schema.TTLAuto},
+		schema.Record{Type: "A", Name: "api", Content: "2.2.2.2", TTL: schema.TTLAuto},
+		schema.Record{Type: "TXT", Name: "@", Content: "v=spf1 -all", TTL: schema.TTLAuto},
+	)
+	dir := t.TempDir()
+
+	// the record types of the domain are backed up
+	execute(t, backupCmd, "--domain", "example.com", "--output", dir+string(filepath.Separator))
+	files, err := filepath.Glob(filepath.Join(dir, "dns_records_example.com_*.json"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("got backup files %v, want one", files)
+	}
+	b, err := backup.Read(files[0], "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.ZoneID != testZoneID || !reflect.DeepEqual(b.Types, []string{"A"}) {
+		t.Fatalf("got backup of zone %s with types %v, want %s with A", b.ZoneID, b.Types, testZoneID)
+	}
+	var got []string
+	for _, r := range b.Records {
+		got = append(got, r.Type+" "+r.Name+" "+r.Content)
+	}
+	sort.Strings(got)
+	want := []string{"A api.example.com 2.2.2.2", "A www.example.com 1.1.1.1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got backed up records %q, want %q", got, want)
+	}
+}
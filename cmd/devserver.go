@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/mrinjamul/flareship/internal/cloudflare/cloudflaretest"
+	"github.com/mrinjamul/flareship/internal/log"
+	"github.com/mrinjamul/flareship/internal/utils"
+	"github.com/mrinjamul/flareship/pkg/schema"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagAddr  string
+	flagZones []string
+	flagSeeds []string
+)
+
+// devServerCmd runs a local fake cloudflare api to rehearse syncs offline
+var devServerCmd = &cobra.Command{
+	Use:    "dev-server",
+	Short:  "run a local fake cloudflare api",
+	Hidden: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		server := cloudflaretest.NewServer()
+
+		// serve every configured zone
+		zones := map[string]string{}
+		for _, domain := range AppConfig.Domains {
+			server.AddZone(domain.ZoneID, domain.Name)
+			zones[domain.Name] = domain.ZoneID
+		}
+		for _, z := range flagZones {
+			id, name, ok := strings.Cut(z, "=")
+			if !ok {
+				log.Fatal("invalid zone %q, expected <zone_id>=<name>", z)
+			}
+			server.AddZone(id, name)
+			zones[name] = id
+		}
+
+		// seed zones from records files
+		for _, seed := range flagSeeds {
+			name, file, ok := strings.Cut(seed, "=")
+			if !ok {
+				log.Fatal("invalid seed %q, expected <domain>=<records file>", seed)
+			}
+			zoneID, ok := zones[name]
+			if !ok {
+				log.Fatal("unknown zone %s for seed %s", name, file)
+			}
+			entries, err := utils.GetRecords(file)
+			if err != nil {
+				log.Fatal("fail to parse seed records %s: %v", file, err)
+			}
+			var records []schema.Record
+			for _, entry := range entries {
+				records = append(records, entry.Record)
+			}
+			server.Seed(zoneID, records...)
+			log.Info("seeded %d record(s) into %s from %s", len(records), name, file)
+		}
+
+		for name, id := range zones {
+			log.Info("serving zone %s (%s)", name, id)
+		}
+		log.Info("fake cloudflare api listening on http://%s%s", flagAddr, cloudflaretest.PathPrefix)
+		log.Info("point flareship at it with FLARESHIP_API_BASE_URL=http://%s%s", flagAddr, cloudflaretest.PathPrefix)
+		if err := http.ListenAndServe(flagAddr, server); err != nil {
+			log.Fatal("dev-server failed: %v", err)
+		}
+	},
+}
+
+func init() {
+	devServerCmd.Flags().StringVar(&flagAddr, "addr", "127.0.0.1:8787", "address to listen on")
+	devServerCmd.Flags().StringArrayVar(&flagZones, "zone", nil, "serve an extra zone, e.g. <zone_id>=example.com")
+	devServerCmd.Flags().StringArrayVar(&flagSeeds, "seed", nil, "seed a zone from a records file, e.g. example.com=records.json")
+}
@@ -1,18 +1,48 @@
 package main
 
 import (
-	"github.com/mrinjamul/flareship/internal/cloudflare"
+	"os"
+
 	"github.com/mrinjamul/flareship/internal/log"
-	"github.com/mrinjamul/flareship/internal/utils"
-	"github.com/mrinjamul/flareship/pkg/schema"
+	"github.com/mrinjamul/flareship/internal/report"
+	"github.com/mrinjamul/flareship/pkg/plan"
 	"github.com/spf13/cobra"
 )
 
+var (
+	flagExitCode bool
+	flagUnified  bool
+	flagStat     bool
+	flagColor    string
+)
+
 // diffCmd represents the diff command
 var diffCmd = &cobra.Command{
 	Use:   "diff",
 	Short: "show differences between local and remote DNS records",
+	Long: `Show differences between local and remote DNS records.
+
+Updates show every changed field, before and after. --unified prints the
+changes like git diff, from the remote records (-) to the local ones (+), and
+--stat the number of changes per domain and record type. Both write to stdout
+and are colored on a terminal unless --color=never.
+
+With --exit-code, the exit status is 0 if there are no differences, 2 if there
+are, including record sets in conflict with unmanaged records, and 1 on errors.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		format := outputFormat()
+		if flagUnified || flagStat {
+			if format != report.Text {
+				log.Fatal("--unified and --stat cannot be used with --output %s", format)
+			}
+			// stdout carries the diff
+			log.SetOutput(os.Stderr)
+		}
+		color := useColor()
+		results := report.NewChanges()
+		var sets []plan.ChangeSet
+		var drift bool
+
 		log.Info("flareship CLI is running 🌟")
 		log.Info("diff started...")
 
@@ -23,121 +53,48 @@ var diffCmd = &cobra.Command{
 				}
 			}
 
-			domainName := domain.Name
-			recordsFile := domain.RecordFile
-			restrictedFile := domain.RestrictedFile
-			zoneID := domain.ZoneID
-			token := domain.CFToken
-
-			// Set enabled records if it is null
-			if len(domain.RecordTypes) == 0 {
-				EnabledRecordType = []string{"A", "CNAME"}
-			} else {
-				EnabledRecordType = domain.RecordTypes
-			}
-
-			log.Info("diff for %s ...", domainName)
-
-			// gather from remote
-			log.Info("gathering DNS Records from cloudflare api...")
-			registeredRecords := cloudflare.ReadAllRecords(zoneID, token, EnabledRecordType)
-			log.Info("got %d registered DNS Records on cf", len(registeredRecords))
-
-			// gather from local
-			log.Info("gathering DNS Records from repository...")
-			localRecords, err := utils.GetDNSRecords(recordsFile, EnabledRecordType)
+			logger := log.With(log.Fields{"domain": domain.Name})
+			logger.Info("diff for %s ...", domain.Name)
+			cs, _, err := planDomain(cmd.Context(), newClient(domain), domain)
 			if err != nil {
-				log.Error("fail to parse local DNS records: %v", err)
-			}
-			for id := range localRecords {
-				localRecords[id].TTL = 1
-				if localRecords[id].Name == "@" {
-					localRecords[id].Name = domainName
-				} else {
-					localRecords[id].Name = localRecords[id].Name + "." + domainName
-				}
-			}
-			log.Info("got %d local CNAME Records in repo", len(localRecords))
-
-			// remove restricted subdomains
-			log.Info("removing restricted subdomains...")
-			localRecords, removedRecords := utils.RemoveRestrictedSubdomains(restrictedFile, localRecords)
-			log.Info("got %d local CNAME Records after removing restricted subdomains", len(localRecords))
-			log.Info("removed %d restricted subdomains", len(removedRecords))
-
-			var createdRecords []schema.Record
-			var updatedRecords []schema.Record
-			var deletedRecords []schema.Record
-
-			log.Info("inspecting DNS records for differences..")
-
-			// Find created and updated records
-			for _, localRecord := range localRecords {
-				found := false
-				for _, registeredRecord := range registeredRecords {
-					if localRecord.Name == registeredRecord.Name && localRecord.Type == registeredRecord.Type {
-						found = true
-						if localRecord.Content != registeredRecord.Content || localRecord.Proxied != registeredRecord.Proxied {
-							updatedRecords = append(updatedRecords, localRecord)
-						}
-						break
-					}
-				}
-				if !found {
-					createdRecords = append(createdRecords, localRecord)
-				}
-			}
-
-			// Find deleted records
-			for _, registeredRecord := range registeredRecords {
-				found := false
-				for _, localRecord := range localRecords {
-					if registeredRecord.Name == localRecord.Name && registeredRecord.Type == localRecord.Type {
-						found = true
-						break
-					}
-				}
-				if !found {
-					deletedRecords = append(deletedRecords, registeredRecord)
-				}
+				logger.With(log.Fields{"error": err}).Error("diff failed for %s: %v", domain.Name, err)
+				results.Fail(domain.Name, err)
+				continue
 			}
 
-			log.Info("Differences for %s:", domainName)
-			log.Info("--------------------------------------------------------------------------------")
-
-			if len(createdRecords) > 0 {
-				log.Info("Records to be created:")
-				for _, r := range createdRecords {
-					log.Info("+ %-10s %-30s %-40s", r.Type, r.Name, r.Content)
-				}
-			}
-
-			if len(updatedRecords) > 0 {
-				log.Info("Records to be updated:")
-				for _, r := range updatedRecords {
-					// For updated records, show both old and new values if possible
-					// This requires fetching the old record's content, which is not directly available here.
-					// For simplicity, just show the new value for now.
-					log.Info("~ %-10s %-30s %-40s (new)", r.Type, r.Name, r.Content)
-				}
-			}
-
-			if len(deletedRecords) > 0 {
-				log.Info("Records to be deleted:")
-				for _, r := range deletedRecords {
-					log.Info("- %-10s %-30s %-40s", r.Type, r.Name, r.Content)
-				}
+			results.Add(cs)
+			sets = append(sets, cs)
+			// conflicts and skipped records are differences sync cannot resolve
+			drift = drift || !cs.Resolved()
+			if !flagUnified && !flagStat {
+				log.Info("Differences for %s:", domain.Name)
+				log.Info("--------------------------------------------------------------------------------")
+				printChangeSet(cs)
+				log.Info("--------------------------------------------------------------------------------")
 			}
+			logger.Info("diff completed for %s 🎉", domain.Name)
+		}
+		writeReport(format, results)
+		if flagStat {
+			report.WriteStat(os.Stdout, sets, color)
+		}
+		if flagUnified {
+			report.WriteUnified(os.Stdout, sets, color)
+		}
 
-			if len(createdRecords) == 0 && len(updatedRecords) == 0 && len(deletedRecords) == 0 {
-				log.Info("No differences found.")
-			}
-			log.Info("--------------------------------------------------------------------------------")
-			log.Info("diff completed for %s 🎉", domainName)
+		// like git diff --exit-code, errors take precedence and exit 1 in main
+		if flagExitCode && drift && log.Errors() == 0 {
+			exit(2)
 		}
 	},
 }
 
 func init() {
+	diffCmd.Flags().BoolVar(&flagAdopt, "adopt", false, "claim unmanaged remote records in the record sets of local records")
 	diffCmd.Flags().StringVar(&flagDomain, "domain", "", "specify the domain name")
+	addOutputFlag(diffCmd)
+	diffCmd.Flags().BoolVar(&flagExitCode, "exit-code", false, "exit with 2 if there are differences, 0 if not")
+	diffCmd.Flags().BoolVarP(&flagUnified, "unified", "u", false, "print the changes as a unified diff")
+	diffCmd.Flags().BoolVar(&flagStat, "stat", false, "print the number of changes per domain and record type")
+	diffCmd.Flags().StringVar(&flagColor, "color", "auto", "color the unified diff and stat: auto, always or never")
 }
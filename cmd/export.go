@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/mrinjamul/flareship/internal/log"
+	"github.com/mrinjamul/flareship/pkg/schema"
+	"github.com/mrinjamul/flareship/pkg/zonefile"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagFormat string
+	flagRemote bool
+)
+
+// exportCmd writes the records of the domains in another format
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "export records as a zone file",
+	Long: `Export the local records, or the remote ones with --remote, as an
+RFC 1035 zone file (BIND). Records with an automatic TTL, or the default_ttl
+of the domain, use the $TTL of the file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if flagFormat != "zonefile" {
+			log.Fatal("unsupported format %q, use zonefile", flagFormat)
+		}
+
+		var domains []schema.DomainConfig
+		for _, domain := range AppConfig.Domains {
+			if flagDomain != "" {
+				if flagDomain != domain.Name {
+					continue
+				}
+			}
+			domains = append(domains, domain)
+		}
+		if flagOut != "" && len(domains) > 1 {
+			log.Fatal("%d domains would be written to %s, use --domain", len(domains), flagOut)
+		}
+
+		var w io.Writer = os.Stdout
+		if flagOut == "" {
+			// stdout carries the zone file
+			log.SetOutput(os.Stderr)
+		} else {
+			file, err := os.Create(flagOut)
+			if err != nil {
+				log.Fatal("Failed to create %s: %v", flagOut, err)
+			}
+			defer file.Close()
+			w = file
+		}
+
+		for _, domain := range domains {
+			types := enabledTypes(domain)
+			var records []schema.Record
+			var err error
+			if flagRemote {
+				log.Info("gathering DNS Records for %s from cloudflare api...", domain.Name)
+				records, err = newClient(domain).ReadAllRecords(cmd.Context(), domain.ZoneID, types)
+			} else {
+				records, err = localRecords(domain, types)
+			}
+			if err != nil {
+				log.Fatal("%v", err)
+			}
+			if err := zonefile.Write(w, domain.Name, domain.DefaultTTL, records); err != nil {
+				log.Fatal("Failed to write zone file: %v", err)
+			}
+			log.Info("exported %d record(s) of %s", len(records), domain.Name)
+		}
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringVar(&flagFormat, "format", "zonefile", "output format, only zonefile is supported")
+	exportCmd.Flags().BoolVar(&flagRemote, "remote", false, "export the remote records instead of the local ones")
+	exportCmd.Flags().StringVarP(&flagOut, "out", "o", "", "write to a file instead of stdout")
+	exportCmd.Flags().StringVar(&flagDomain, "domain", "", "specify the domain name")
+}
@@ -1,11 +1,10 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
 
 	"github.com/mrinjamul/flareship/internal/log"
+	"github.com/mrinjamul/flareship/internal/recordfile"
 	"github.com/mrinjamul/flareship/internal/utils"
 	"github.com/mrinjamul/flareship/pkg/schema"
 	"github.com/spf13/cobra"
@@ -19,6 +18,10 @@ var fmtCmd = &cobra.Command{
 	Use:   "fmt",
 	Short: "format the records",
 	Run: func(cmd *cobra.Command, args []string) {
+		if flagCheck {
+			checkRecords()
+			return
+		}
 		for _, domain := range AppConfig.Domains {
 
 			if flagDomain != "" {
@@ -41,128 +44,66 @@ var fmtCmd = &cobra.Command{
 				continue
 			}
 
-			if flagCheck {
-				var warn bool
-				var hasError bool
-				var errorsList []string
-				var records []schema.Records
-				records, err := utils.GetRecords(recordsFile)
-				if err != nil {
-					log.Error("Failed to parse records: %v", err)
-				}
-				for id, record := range records {
-					log.Info("ID: %d", id+1)
-					log.Info("%s: %s %s", record.Record.Type, record.Record.Name, record.Record.Content)
-					if !record.Record.Proxied && (record.Record.Type == "A" || record.Record.Type == "AAAA" || record.Record.Type == "CNAME") {
-						warn = true
-						log.Info("WARN - Proxied is false")
-						log.Info("WARN - Please check the record")
-					}
-					if record.Record.Type == "" {
-						log.Error("Record type cannot be empty")
-						os.Exit(1)
-					}
-					if record.Record.Name == "" {
-						log.Error("Record name cannot be empty")
-						os.Exit(1)
-					}
-					if record.Record.Content == "" {
-						log.Error("Record content cannot be empty")
-						os.Exit(1)
-					}
-				}
-
-				// Check if the records includes restricted subdomains
-				var enabledRecordType []string = []string{"A", "AAAA", "CNAME", "TXT", "MX", "SRV"}
-				localRecords, err := utils.GetDNSRecords(recordsFile, enabledRecordType)
-				if err != nil {
-					log.Error("Failed to parse DNS records: %v", err)
-				}
-				_, restrictedRecords := utils.RemoveRestrictedSubdomains(restrictedFile, localRecords)
-				if len(restrictedRecords) > 0 {
-					hasError = true
-					log.Info("Restricted subdomains found")
-					log.Info("Please check the record")
-					errorsList = append(errorsList, "Restricted subdomains found")
-					fmt.Println()
-					// print restricted records
-					for _, record := range restrictedRecords {
-						log.Info("%s: %s %s", record.Type, record.Name, record.Content)
-					}
-				}
-
-				if hasError {
-					for _, error := range errorsList {
-						log.Info("%s", error)
-					}
-					log.Info("Run `flareship fmt` to fix the errors")
-					log.Error("Test failed")
-				}
-
-				log.Info("%d record(s) found and are valid", len(records))
-				if warn {
-					log.Info("WARN - Some records have warnings")
-					log.Info("WARN - Please check the records")
-				}
-				log.Info("PASS - All checks passed.")
-				continue
-			}
-
-			records, err := utils.GetRecords(recordsFile)
+			files, err := recordfile.ReadAll(recordsFile)
 			if err != nil {
-				log.Error("Failed to parse local DNS records: %v", err)
+				log.Fatal("Failed to parse local DNS records: %v", err)
 			}
 			restrictedList := utils.ReadRestrictedRecords(restrictedFile)
-			var removeList []int
+			// removeList holds the indexes of the restricted entries per file
+			removeList := map[*recordfile.File][]int{}
+			var removeCount int
 
 			var count uint
 			var removed bool
-			for i := range records {
-				var flag bool
-				records[i].Record.Proxiable = true
-				// Set Proxied to true if the record type is A, AAAA or CNAME
-				if (records[i].Record.Type == "A" || records[i].Record.Type == "AAAA" || records[i].Record.Type == "CNAME") && !records[i].Record.Proxied {
-					log.Info("Setting Proxied to true for %s", records[i].Record.Name)
-					records[i].Record.Proxied = true
-					count++
-					flag = true
-				}
-				// Set TTL to 1 if the record type is A, AAAA or CNAME
-				if (records[i].Record.Type == "A" || records[i].Record.Type == "AAAA" || records[i].Record.Type == "CNAME") && records[i].Record.TTL == 0 {
-					log.Info("Setting TTL to auto for %s", records[i].Record.Name)
-					records[i].Record.TTL = 1
-					if !flag {
+			for _, file := range files {
+				records := file.Entries
+				for i := range records {
+					var flag bool
+					records[i].Record.Proxiable = schema.Proxiable(records[i].Record.Type)
+					// Set Proxied to true if the record type is A, AAAA or CNAME
+					if records[i].Record.Proxiable && !records[i].Record.Proxied {
+						log.Info("Setting Proxied to true for %s", records[i].Record.Name)
+						records[i].Record.Proxied = true
 						count++
+						flag = true
+					}
+					// Set TTL to auto if the record is proxied
+					if records[i].Record.Proxied && records[i].Record.TTL != schema.TTLAuto {
+						log.Info("Setting TTL to auto for %s", records[i].Record.Name)
+						records[i].Record.TTL = schema.TTLAuto
+						if !flag {
+							count++
+						}
+						flag = true
+					}
+					if utils.IsRestricted(records[i].Record.Name, restrictedList) {
+						// remove this record from the records
+						removeList[file] = append(removeList[file], i)
+						removeCount++
 					}
-					flag = true
-				}
-				if utils.IsRestricted(records[i].Record.Name, restrictedList) {
-					// remove this record from the records
-					removeList = append(removeList, i)
 				}
-
 			}
 			// remove restricted records
-			if len(removeList) > 0 {
+			if removeCount > 0 {
 				if ok := utils.ConfirmPrompt("Do you want to remove restricted subdomains?"); ok {
-					count += uint(len(removeList))
+					count += uint(removeCount)
 					removed = true
-					for _, i := range removeList {
-						records = removeRecords(records, i)
+					for file, indexes := range removeList {
+						// remove from the end to keep the indexes valid
+						for j := len(indexes) - 1; j >= 0; j-- {
+							file.Remove(indexes[j])
+						}
 					}
 				}
 			}
-			// write the records to the file
-			data, err := json.MarshalIndent(records, "", "\t")
-			if err != nil {
-				log.Error("Failed to convert records to JSON: %v", err)
-			}
-			err = os.WriteFile(recordsFile, data, 0644)
-			if err != nil {
-				log.Error("Failed to write records to file: %v", err)
+			// write the records to the files in their own format
+			for _, file := range files {
+				if err := file.Write(); err != nil {
+					log.Fatal("Failed to write records to %s: %v", file.Path, err)
+				}
 			}
 			if removed {
-				log.Info("%d record(s) removed", len(removeList))
+				log.Info("%d record(s) removed", removeCount)
 			}
 			log.Info("%d record(s) formatted", count)
 			log.Info("Formatting record complete!")
@@ -175,8 +116,76 @@ func init() {
 	fmtCmd.Flags().StringVar(&flagDomain, "domain", "", "specify the domain name")
 }
 
-// removeRecords removes the records from the records file
-func removeRecords(records []schema.Records, i int) []schema.Records {
-	records[i] = records[len(records)-1]
-	return records[:len(records)-1]
+// checkRecords checks the records of every domain without changing them. All
+// problems are reported before the command fails.
+func checkRecords() {
+	var problems []string
+	var warnings int
+	for _, domain := range AppConfig.Domains {
+		if flagDomain != "" && flagDomain != domain.Name {
+			continue
+		}
+		log.Info("Checking records for %s...", domain.Name)
+		domainProblems, domainWarnings := checkDomain(domain)
+		for _, problem := range domainProblems {
+			log.With(log.Fields{"domain": domain.Name}).Error("%s", problem)
+			problems = append(problems, domain.Name+": "+problem)
+		}
+		warnings += domainWarnings
+	}
+
+	if warnings > 0 {
+		log.Warn("%d record(s) have warnings, please check them", warnings)
+	}
+	if len(problems) > 0 {
+		log.Info("%d problem(s) found:", len(problems))
+		for _, problem := range problems {
+			log.Info("  %s", problem)
+		}
+		log.Info("Run `flareship fmt` to fix the errors")
+		log.Error("FAIL - %d problem(s) found", len(problems))
+		return
+	}
+	log.Info("PASS - All checks passed.")
+}
+
+// checkDomain returns the problems of the records of the domain, and the
+// number of records with warnings
+func checkDomain(domain schema.DomainConfig) ([]string, int) {
+	if domain.RecordFile == "" {
+		return []string{"no record_file is configured"}, 0
+	}
+	records, err := utils.GetRecords(domain.RecordFile)
+	if err != nil {
+		return []string{fmt.Sprintf("failed to parse records: %v", err)}, 0
+	}
+
+	var problems []string
+	var warnings int
+	for id, record := range records {
+		log.Debug("ID: %d %s: %s %s", id+1, record.Record.Type, record.Record.Name, recordValue(record.Record))
+		if !record.Record.Proxied && schema.Proxiable(record.Record.Type) {
+			warnings++
+			log.With(log.Fields{"domain": domain.Name, "type": record.Record.Type, "name": record.Record.Name}).Warn("%s %s is not proxied", record.Record.Type, record.Record.Name)
+		}
+		if err := record.Record.Validate(); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if domain.RestrictedFile != "" {
+		var localRecords []schema.Record
+		for _, record := range records {
+			localRecords = append(localRecords, record.Record)
+		}
+		_, restrictedRecords := utils.RemoveRestrictedSubdomains(domain.RestrictedFile, localRecords)
+		for _, record := range restrictedRecords {
+			problems = append(problems, fmt.Sprintf("%s %s: restricted subdomain", record.Type, record.Name))
+		}
+	}
+
+	if len(problems) == 0 {
+		log.Info("%d record(s) found and are valid", len(records))
+	}
+	return problems, warnings
 }
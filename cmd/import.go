@@ -0,0 +1,500 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mrinjamul/flareship/internal/fileformat"
+	"github.com/mrinjamul/flareship/internal/log"
+	"github.com/mrinjamul/flareship/internal/recordfile"
+	"github.com/mrinjamul/flareship/internal/utils"
+	"github.com/mrinjamul/flareship/pkg/plan"
+	"github.com/mrinjamul/flareship/pkg/schema"
+	"github.com/mrinjamul/flareship/pkg/zonefile"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagFromZonefile string
+	flagOrigin       string
+	flagOverwrite    bool
+)
+
+// importPlaceholder is the owner and description of imported records whose
+// metadata is unknown, to be filled in by hand
+const importPlaceholder = "TODO"
+
+// importCmd converts records from other sources into a records file
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "import records into a records file",
+	Long: `Import records into a records file.
+
+With --from-zonefile, the records of an RFC 1035 zone file (BIND) are
+converted. Names are made relative to the origin, which is the --origin flag,
+the --domain flag or the $ORIGIN of the file. The records are printed as JSON,
+or written to the --out file in the format of its extension.
+
+With --domain alone, the remote records of the domain are imported into its
+record_file, or the --out file. Records already in the file keep their
+metadata and local values, differences are reported; --overwrite takes the
+remote values and settings instead. New records get the owner and description
+of their flareship comment, or "TODO". With a directory or glob record_file,
+each new subdomain gets its own file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		switch {
+		case flagFromZonefile != "":
+			importZonefile()
+		case flagDomain != "":
+			importRemote(cmd.Context())
+		default:
+			log.Fatal("nothing to import, use --from-zonefile or --domain")
+		}
+	},
+}
+
+func init() {
+	importCmd.Flags().StringVar(&flagFromZonefile, "from-zonefile", "", "import the records of a BIND zone file")
+	importCmd.Flags().StringVar(&flagOrigin, "origin", "", "origin of the relative names of the zone file")
+	importCmd.Flags().StringVarP(&flagOut, "out", "o", "", "write the records to a file instead of stdout or the record_file")
+	importCmd.Flags().StringVar(&flagDomain, "domain", "", "specify the domain name")
+	importCmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "show the changes without writing the records files")
+	importCmd.Flags().BoolVar(&flagOverwrite, "overwrite", false, "replace differing local values and settings with the remote ones")
+}
+
+// importZonefile converts the records of the --from-zonefile file
+func importZonefile() {
+	if flagOut == "" {
+		// stdout carries the records
+		log.SetOutput(os.Stderr)
+	}
+
+	origin := flagOrigin
+	if origin == "" {
+		origin = flagDomain
+	}
+	file, err := os.Open(flagFromZonefile)
+	if err != nil {
+		log.Fatal("Failed to open zone file: %v", err)
+	}
+	defer file.Close()
+	zone, err := zonefile.Parse(file, origin)
+	if err != nil {
+		log.Fatal("Failed to parse %s: %v", flagFromZonefile, err)
+	}
+	for _, skipped := range zone.Skipped {
+		log.Warn("skipping %s record, the type is not supported", skipped)
+	}
+
+	entries := zoneEntries(zone)
+	log.Info("imported %d record(s) of %s", len(entries), zone.Origin)
+	if zone.TTL > 0 {
+		log.Info("records using $TTL have no ttl, set \"default_ttl\": %d on %s to keep it", zone.TTL, zone.Origin)
+	}
+
+	if flagOut == "" {
+		data, err := fileformat.Marshal(fileformat.JSON, entries)
+		if err != nil {
+			log.Fatal("Failed to convert records to JSON: %v", err)
+		}
+		os.Stdout.Write(data)
+		return
+	}
+	if _, err := os.Stat(flagOut); err == nil {
+		log.Fatal("%s already exists", flagOut)
+	}
+	out := recordfile.File{Path: flagOut, Format: fileformat.Detect(flagOut), Entries: entries}
+	if err := out.Write(); err != nil {
+		log.Fatal("Failed to write records to file: %v", err)
+	}
+	log.Info("records written to %s", flagOut)
+}
+
+// importRemote merges the remote records of the --domain domain into its
+// records files
+func importRemote(ctx context.Context) {
+	domain, err := restoreDomain(flagDomain)
+	if err != nil {
+		log.Fatal("%v", err)
+	}
+	target := domain.RecordFile
+	if flagOut != "" {
+		target = flagOut
+	}
+	if target == "" {
+		log.Fatal("%s has no record_file, use --out", domain.Name)
+	}
+
+	log.Info("gathering DNS Records from cloudflare api...")
+	remote, err := newClient(domain).ReadAllRecords(ctx, domain.ZoneID, enabledTypes(domain))
+	if err != nil {
+		log.Fatal("fail to fetch remote DNS records: %v", err)
+	}
+	log.Info("got %d registered DNS Records on cf", len(remote))
+
+	entries := remoteEntries(domain, remote)
+	if domain.RestrictedFile != "" {
+		restricted := utils.ReadRestrictedRecords(domain.RestrictedFile)
+		var allowed []schema.Records
+		for _, entry := range entries {
+			if utils.IsRestricted(entry.Record.Name, restricted) {
+				log.Info("skipping restricted subdomain %s", entry.Record.Name)
+				continue
+			}
+			allowed = append(allowed, entry)
+		}
+		entries = allowed
+	}
+
+	files, err := mergeEntries(domain, target, entries)
+	if err != nil {
+		log.Fatal("Failed to merge records: %v", err)
+	}
+	for _, file := range files {
+		if flagDryRun {
+			log.Info("would write %s", file.Path)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(file.Path), 0755); err != nil {
+			log.Fatal("Failed to write records to file: %v", err)
+		}
+		if err := file.Write(); err != nil {
+			log.Fatal("Failed to write records to file: %v", err)
+		}
+		log.Info("records written to %s", file.Path)
+	}
+
+	for _, r := range remote {
+		if !plan.Owned(r) {
+			log.Info("imported records are not managed by flareship yet, run `flareship sync --adopt` to claim them")
+			break
+		}
+	}
+}
+
+// remoteEntries converts the remote records of the domain to records file
+// entries with relative names, sorted by name with the apex first. The owner,
+// repo and description come from the flareship comment of the record,
+// placeholders are used if it has none.
+func remoteEntries(domain schema.DomainConfig, remote []schema.Record) []schema.Records {
+	var entries []schema.Records
+	for _, r := range remote {
+		name, ok := relativeName(r.Name, domain.Name)
+		if !ok {
+			log.Warn("skipping %s record %s, it is outside of %s", r.Type, r.Name, domain.Name)
+			continue
+		}
+		var entry schema.Records
+		entry.Description, entry.Repo, entry.Owner = parseComment(r.Comment)
+		if entry.Description == "" {
+			entry.Description = importPlaceholder
+		}
+		if entry.Owner == (schema.Owner{}) {
+			entry.Owner.Username = importPlaceholder
+		}
+		entry.Record = schema.Record{
+			Type:      r.Type,
+			Name:      name,
+			Proxiable: schema.Proxiable(r.Type),
+			Proxied:   r.Proxied,
+			Priority:  r.Priority,
+			Data:      r.Data,
+		}
+		if r.Data == nil {
+			entry.Record.Content = r.Content
+		}
+		// the TTL is left out when it is the default one
+		if recordTTL(domain, entry.Record) != r.TTL {
+			entry.Record.TTL = r.TTL
+		}
+		// the tags of the domain are added by sync
+		domainTags := recordTags(domain, schema.Records{Repo: entry.Repo, Owner: entry.Owner})
+		for _, tag := range r.Tags {
+			if !containsFold(domainTags, tag) {
+				entry.Record.Tags = append(entry.Record.Tags, tag)
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i].Record, entries[j].Record
+		if a.Name != b.Name {
+			if a.Name == "@" || b.Name == "@" {
+				return a.Name == "@"
+			}
+			return a.Name < b.Name
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return recordValue(a) < recordValue(b)
+	})
+	return entries
+}
+
+// parseComment returns the metadata of a comment written by sync, see
+// recordComment. Any other comment is the description.
+func parseComment(comment string) (description, repo string, owner schema.Owner) {
+	if !strings.Contains(comment, plan.OwnerMarker) {
+		return strings.TrimSpace(comment), "", owner
+	}
+	var note []string
+	for _, field := range strings.Fields(comment) {
+		switch {
+		case field == plan.OwnerMarker:
+		case strings.HasPrefix(field, "owner=") && len(note) == 0:
+			if value := strings.TrimPrefix(field, "owner="); strings.Contains(value, "@") {
+				owner.Email = value
+			} else {
+				owner.Username = value
+			}
+		case strings.HasPrefix(field, "repo=") && len(note) == 0:
+			repo = strings.TrimPrefix(field, "repo=")
+		default:
+			note = append(note, field)
+		}
+	}
+	return strings.Join(note, " "), repo, owner
+}
+
+// mergeEntries merges the imported entries into the records files of target,
+// a record_file setting, and returns the files to write. An entry already in
+// a file keeps its metadata. Differing settings, or remote values of a record
+// set with other local values, are conflicts: the local entries are kept
+// unless --overwrite is set. A new entry is added after the entries of its
+// name, or to a new file named after it when target is a directory or a glob.
+func mergeEntries(domain schema.DomainConfig, target string, entries []schema.Records) ([]*recordfile.File, error) {
+	info, statErr := os.Stat(target)
+	glob := statErr != nil && strings.ContainsAny(target, "*?[")
+	layout := statErr == nil && info.IsDir() || glob
+
+	var files []*recordfile.File
+	if _, err := recordfile.Paths(target); err == nil {
+		if files, err = recordfile.ReadAll(target); err != nil {
+			return nil, err
+		}
+	} else if !glob && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if !layout && len(files) == 0 {
+		files = []*recordfile.File{{Path: target, Format: fileformat.Detect(target)}}
+	}
+
+	// new files of the directory layout
+	dir, ext := target, ""
+	if glob {
+		dir = filepath.Dir(target)
+		if e := filepath.Ext(target); !strings.ContainsAny(e, "*?[") {
+			ext = e
+		}
+	}
+	if ext == "" && len(files) > 0 {
+		ext = filepath.Ext(files[0].Path)
+	}
+	if ext == "" {
+		ext = ".json"
+	}
+
+	var changed []*recordfile.File
+	markChanged := func(file *recordfile.File) {
+		for _, f := range changed {
+			if f == file {
+				return
+			}
+		}
+		changed = append(changed, file)
+	}
+
+	var remote []schema.Record
+	for _, entry := range entries {
+		remote = append(remote, entry.Record)
+	}
+	remoteSets := plan.Group(remote)
+
+	var added, updated, unchanged, conflicts int
+	for _, entry := range entries {
+		record := entry.Record
+		logger := log.With(log.Fields{"domain": domain.Name, "type": record.Type, "name": record.Name})
+
+		file, i := findEntry(files, record)
+		if file == nil {
+			var found bool
+			file, i, found = findConflict(files, record, remoteSets[plan.KeyOf(record)])
+			if found && !flagOverwrite {
+				logger.Warn("%s %s: remote value %s is not in the records file, keeping the local record set", record.Type, record.Name, recordValue(record))
+				conflicts++
+				continue
+			}
+		}
+		if file != nil {
+			local := &file.Entries[i].Record
+			diff := importDiff(domain, *local, record)
+			switch {
+			case len(diff) == 0:
+				unchanged++
+			case flagOverwrite:
+				logger.Info("~ %s %s", record.Type, record.Name)
+				for _, f := range diff {
+					logger.With(log.Fields{"field": f.Field, "old": f.Old, "new": f.New}).Info("    %-8s %s → %s", f.Field+":", orNone(f.Old), orNone(f.New))
+				}
+				importSettings(local, record)
+				markChanged(file)
+				updated++
+			default:
+				logger.Warn("%s %s differs from the remote record, keeping the local values:", record.Type, record.Name)
+				for _, f := range diff {
+					logger.With(log.Fields{"field": f.Field, "local": f.Old, "remote": f.New}).Warn("    %-8s local %s, remote %s", f.Field+":", orNone(f.Old), orNone(f.New))
+				}
+				conflicts++
+			}
+			continue
+		}
+
+		file, i = findName(files, record.Name)
+		if file == nil && layout {
+			path := filepath.Join(dir, strings.ReplaceAll(record.Name, "*", "_wildcard")+ext)
+			for _, f := range files {
+				if f.Path == path {
+					file, i = f, len(f.Entries)
+				}
+			}
+			if file == nil {
+				file = &recordfile.File{Path: path, Format: fileformat.Detect(path), Single: true}
+				files = append(files, file)
+			}
+		} else if file == nil {
+			file, i = files[0], len(files[0].Entries)
+		}
+		file.Insert(i, entry)
+		markChanged(file)
+		added++
+	}
+	log.Info("%d record(s) added, %d updated, %d unchanged, %d conflicting", added, updated, unchanged, conflicts)
+	if conflicts > 0 {
+		log.Warn("%d local record(s) differ from the remote ones and were kept, run with --overwrite to take the remote values", conflicts)
+	}
+	if added > 0 {
+		log.Info("fill in the %q owners and descriptions of the new records", importPlaceholder)
+	}
+	return changed, nil
+}
+
+// findEntry returns the file and index of the entry holding the record
+func findEntry(files []*recordfile.File, record schema.Record) (*recordfile.File, int) {
+	for _, file := range files {
+		for i, entry := range file.Entries {
+			if plan.KeyOf(entry.Record) == plan.KeyOf(record) && plan.SameValue(entry.Record, record) {
+				return file, i
+			}
+		}
+	}
+	return nil, 0
+}
+
+// findConflict reports whether the record set of the record has local
+// entries. It returns the file and index of the first one whose value is not
+// in the remote record set, to be replaced by the record.
+func findConflict(files []*recordfile.File, record schema.Record, remote []schema.Record) (*recordfile.File, int, bool) {
+	var found bool
+	for _, file := range files {
+		for i, entry := range file.Entries {
+			if plan.KeyOf(entry.Record) != plan.KeyOf(record) {
+				continue
+			}
+			found = true
+			if indexOfValue(remote, entry.Record) < 0 {
+				return file, i, true
+			}
+		}
+	}
+	return nil, 0, found
+}
+
+// indexOfValue returns the index of the record with the value of record, or -1
+func indexOfValue(records []schema.Record, record schema.Record) int {
+	for i, r := range records {
+		if plan.SameValue(r, record) {
+			return i
+		}
+	}
+	return -1
+}
+
+// findName returns the first file with entries of the name, and the index
+// after its last one
+func findName(files []*recordfile.File, name string) (*recordfile.File, int) {
+	for _, file := range files {
+		last := -1
+		for i, entry := range file.Entries {
+			if strings.EqualFold(entry.Record.Name, name) {
+				last = i
+			}
+		}
+		if last >= 0 {
+			return file, last + 1
+		}
+	}
+	return nil, 0
+}
+
+// importDiff returns the fields of the local record which differ from the
+// imported one, with the default TTL resolved. Comments and tags are left to
+// sync.
+func importDiff(domain schema.DomainConfig, local, imported schema.Record) []plan.FieldChange {
+	local.TTL = recordTTL(domain, local)
+	imported.TTL = recordTTL(domain, imported)
+	var diff []plan.FieldChange
+	for _, f := range plan.Diff(local, imported) {
+		if f.Field != "comment" && f.Field != "tags" {
+			diff = append(diff, f)
+		}
+	}
+	return diff
+}
+
+// importSettings replaces the value and settings of a local record with the
+// imported ones
+func importSettings(local *schema.Record, imported schema.Record) {
+	local.Content = imported.Content
+	local.Data = imported.Data
+	local.Proxied = imported.Proxied
+	local.TTL = imported.TTL
+	local.Priority = imported.Priority
+}
+
+// zoneEntries converts the records of the zone to records file entries with
+// names relative to its origin. Records outside of the origin are skipped.
+func zoneEntries(zone *zonefile.Zone) []schema.Records {
+	var entries []schema.Records
+	for _, record := range zone.Records {
+		name, ok := relativeName(record.Name, zone.Origin)
+		if !ok {
+			log.Warn("skipping %s record %s, it is outside of %s", record.Type, record.Name, zone.Origin)
+			continue
+		}
+		record.Name = name
+		if err := schema.ValidateTTL(record.TTL); err != nil {
+			log.Warn("%s record %s: %v, using auto", record.Type, record.Name, err)
+			record.TTL = schema.TTLAuto
+		}
+		entries = append(entries, schema.Records{Record: record})
+	}
+	return entries
+}
+
+// relativeName returns the name relative to origin, "@" for the origin
+// itself. It reports false for names outside of origin.
+func relativeName(name, origin string) (string, bool) {
+	name = strings.ToLower(name)
+	switch {
+	case name == origin:
+		return "@", true
+	case strings.HasSuffix(name, "."+origin):
+		return strings.TrimSuffix(name, "."+origin), true
+	}
+	return "", false
+}
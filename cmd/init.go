@@ -90,7 +90,7 @@ var initCmd = &cobra.Command{
 }`
 				err := os.WriteFile(restrictedFileName, []byte(defaultRestricted), 0644)
 				if err != nil {
-					log.Error("Failed to create restricted file %s: %v", restrictedFileName, err)
+					log.Fatal("Failed to create restricted file %s: %v", restrictedFileName, err)
 				} else {
 					log.Info("Created restricted file %s with default content.", restrictedFileName)
 				}
@@ -117,7 +117,7 @@ var initCmd = &cobra.Command{
 
 				err := os.WriteFile(domain.RecordFile, []byte(defaultContent), 0644)
 				if err != nil {
-					log.Error("Failed to create record file %s: %v", domain.RecordFile, err)
+					log.Fatal("Failed to create record file %s: %v", domain.RecordFile, err)
 				} else {
 					log.Info("Created record file %s with default content.", domain.RecordFile)
 				}
@@ -135,8 +135,7 @@ var initCmd = &cobra.Command{
 
 		err := config.InitConfig(&cfg)
 		if err != nil {
-			log.Error("Failed to initialize config: %v", err)
-			os.Exit(1)
+			log.Fatal("Failed to initialize config: %v", err)
 		} else {
 			log.Info("Config initialized successfully.")
 			log.Info("For setting this global config, move this config to:")
@@ -1,11 +1,8 @@
 package main
 
 import (
-	"fmt" // Keep fmt for Sprintf for table formatting
-	"strings"
-
-	"github.com/mrinjamul/flareship/internal/cloudflare"
 	"github.com/mrinjamul/flareship/internal/log"
+	"github.com/mrinjamul/flareship/internal/report"
 	"github.com/mrinjamul/flareship/internal/utils"
 	"github.com/spf13/cobra"
 )
@@ -20,6 +17,8 @@ var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "list all records from remote/local",
 	Run: func(cmd *cobra.Command, args []string) {
+		format := outputFormat()
+		results := report.NewRecords()
 
 		for _, domain := range AppConfig.Domains {
 
@@ -32,32 +31,25 @@ var listCmd = &cobra.Command{
 			recordFile := domain.RecordFile
 			domainName := domain.Name
 
-			if len(EnabledRecordType) == 0 {
-				EnabledRecordType = domain.RecordTypes
-			}
-
-			if flagTypes != "" {
-				EnabledRecordType = strings.Split(flagTypes, ",")
-				if flagTypes == "all" {
-					// all type of dns records
-					EnabledRecordType = []string{"A", "AAAA", "CNAME", "TXT", "MX", "SRV"}
-				}
-			}
+			types := selectedTypes(domain)
 
 			// list records from local json file
 			if flagLocal {
 				log.Info("gathering DNS Records from local ...")
-				localRecords, err := utils.GetDNSRecords(recordFile, EnabledRecordType)
+				localRecords, err := utils.GetDNSRecords(recordFile, types)
 				if err != nil {
-					log.Error("fail to parse local DNS records: %v", err)
+					log.Fatal("fail to parse local DNS records: %v", err)
 				}
 				log.Info("DNS Records for %s (local):", domainName)
 				log.Info("--------------------------------------------------------------------------------")
 				log.Info("%-10s %-30s %-40s %-5s", "TYPE", "NAME", "CONTENT", "TTL")
 				log.Info("--------------------------------------------------------------------------------")
-				for _, record := range localRecords {
-					log.Info("%-10s %-30s %-40s %-5d", record.Type, fmt.Sprintf("%s.%s", record.Name, domainName), record.Content, record.TTL)
+				for i, record := range localRecords {
+					localRecords[i].Name = qualifyName(record.Name, domainName)
+					localRecords[i].TTL = recordTTL(domain, record)
+					log.Info("%-10s %-30s %-40s %-5d", record.Type, localRecords[i].Name, recordValue(record), localRecords[i].TTL)
 				}
+				results.Add(domainName, localRecords)
 				log.Info("--------------------------------------------------------------------------------")
 				log.Info("got %d registered DNS Records from local records", len(localRecords))
 				continue
@@ -65,17 +57,22 @@ var listCmd = &cobra.Command{
 
 			// gather from remote
 			log.Info("gathering DNS Records for %s from cloudflare api...", domainName)
-			allRecords := cloudflare.ReadAllRecords(domain.ZoneID, domain.CFToken, EnabledRecordType)
+			allRecords, err := newClient(domain).ReadAllRecords(cmd.Context(), domain.ZoneID, types)
+			if err != nil {
+				log.Fatal("fail to fetch remote DNS records: %v", err)
+			}
 			log.Info("DNS Records for %s (remote):", domainName)
 			log.Info("--------------------------------------------------------------------------------")
 			log.Info("%-10s %-30s %-40s %-5s", "TYPE", "NAME", "CONTENT", "TTL")
 			log.Info("--------------------------------------------------------------------------------")
 			for _, record := range allRecords {
-				log.Info("%-10s %-30s %-40s %-5d", record.Type, record.Name, record.Content, record.TTL)
+				log.Info("%-10s %-30s %-40s %-5d", record.Type, record.Name, recordValue(record), record.TTL)
 			}
+			results.Add(domainName, allRecords)
 			log.Info("--------------------------------------------------------------------------------")
 			log.Info("got %d registered DNS Records on cloudflare for %s", len(allRecords), domainName)
 		}
+		writeReport(format, results)
 
 	},
 }
@@ -84,4 +81,5 @@ func init() {
 	listCmd.Flags().StringVarP(&flagTypes, "type", "t", "", "specify the types of records")
 	listCmd.Flags().BoolVarP(&flagLocal, "local", "l", false, "specify the target to list e.g. local")
 	listCmd.Flags().StringVar(&flagDomain, "domain", "", "specify the domain name")
+	addOutputFlag(listCmd)
 }
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 
 	"github.com/mrinjamul/flareship/internal/config"
 	"github.com/mrinjamul/flareship/internal/log" // Import the new log package
@@ -12,9 +14,13 @@ import (
 )
 
 var (
-	flagConfig  string = ""
-	flagVerbose bool   // New global verbose flag
-	AppConfig   *schema.AppConfig
+	flagConfig    string = ""
+	flagVerbose   bool   // New global verbose flag
+	flagQuiet     bool
+	flagLogLevel  string
+	flagLogFormat string
+	flagLogFile   string
+	AppConfig     *schema.AppConfig
 )
 
 func init() {
@@ -24,6 +30,12 @@ func main() {
 	var rootCmd = &cobra.Command{
 		Use:   "flareship",
 		Short: "flareship CLI",
+		// errors are logged by main
+		SilenceErrors: true,
+		// flags are parsed by now
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return setupLog()
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			// Root command
 			var tip string = "tip: "
@@ -39,14 +51,22 @@ func main() {
 	rootCmd.AddCommand(syncCmd)
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(rollbackCmd)
 	rootCmd.AddCommand(diffCmd) // Add the new diff command
+	rootCmd.AddCommand(planCmd)
+	rootCmd.AddCommand(applyCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(devServerCmd)
 	// add flags
 	rootCmd.PersistentFlags().BoolVarP(&flagVerbose, "verbose", "v", false, "enable verbose output") // Add verbose flag
+	rootCmd.PersistentFlags().BoolVarP(&flagQuiet, "quiet", "q", false, "only print warnings and errors")
+	rootCmd.PersistentFlags().StringVar(&flagLogLevel, "log-level", "info", "lowest level of the printed messages: debug, info, warn or error")
+	rootCmd.PersistentFlags().StringVar(&flagLogFormat, "log-format", "text", "format of the messages: text or json")
+	rootCmd.PersistentFlags().StringVar(&flagLogFile, "log-file", "", "also append the messages to a file")
 	rootCmd.Flags().StringVarP(&flagConfig, "config", "c", "", "specify config file location")
 
-	// Initialize logger verbosity
-	log.SetVerbose(flagVerbose)
-
 	// PreRun
 	_, present := os.LookupEnv("FLARESHIP_CONFIG")
 	if present {
@@ -57,13 +77,52 @@ func main() {
 	AppConfig, err = config.LoadConfig(flagConfig)
 
 	if err != nil {
-		log.Error("Failed to load config from %s: %v", flagConfig, err) // Use log.Error
+		log.Fatal("Failed to load config from %s: %v", flagConfig, err)
 	}
 
 	// fmt.Println(AppConfig)
 
-	err = rootCmd.Execute()
+	// Cancel in-flight API requests on interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err = rootCmd.ExecuteContext(ctx)
+	if err != nil {
+		log.Fatal("%v", err)
+	}
+	// errors reported without stopping the command still fail it
+	if log.Errors() > 0 {
+		exit(1)
+	}
+	log.Close()
+}
+
+// exit closes the log file and exits with the status code
+func exit(code int) {
+	log.Close()
+	os.Exit(code)
+}
+
+// setupLog configures the logger from the global flags
+func setupLog() error {
+	level, err := log.ParseLevel(flagLogLevel)
 	if err != nil {
-		log.Error("%v", err) // Use log.Error
+		return err
+	}
+	if flagVerbose {
+		level = log.DebugLevel
+	}
+	format, err := log.ParseFormat(flagLogFormat)
+	if err != nil {
+		return err
+	}
+	log.SetLevel(level)
+	log.SetFormat(format)
+	log.SetQuiet(flagQuiet)
+	if flagLogFile != "" {
+		if err := log.SetFile(flagLogFile); err != nil {
+			return fmt.Errorf("failed to open log file: %w", err)
+		}
 	}
+	return nil
 }
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+
+	"github.com/mrinjamul/flareship/internal/log"
+	"github.com/mrinjamul/flareship/internal/report"
+	"github.com/mrinjamul/flareship/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var flagOutputFormat string
+
+// addOutputFlag adds the --output flag of the commands with machine-readable
+// results
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&flagOutputFormat, "output", string(report.Text), "format of the results: text, json, yaml, table or csv")
+}
+
+// outputFormat returns the --output format. Logs go to stderr when stdout
+// carries the results.
+func outputFormat() report.Format {
+	format, err := report.ParseFormat(flagOutputFormat)
+	if err != nil {
+		log.Fatal("%v", err)
+	}
+	if format != report.Text {
+		log.SetOutput(os.Stderr)
+	}
+	return format
+}
+
+// useColor reports whether to color the output on stdout, from --color. With
+// auto, stdout must be a terminal and NO_COLOR unset.
+func useColor() bool {
+	switch flagColor {
+	case "always":
+		return true
+	case "never":
+		return false
+	case "auto", "":
+		return utils.IsTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""
+	}
+	log.Fatal("invalid color %q, use auto, always or never", flagColor)
+	return false
+}
+
+// writeReport writes the results to stdout in the --output format
+func writeReport(format report.Format, r report.Report) {
+	if err := report.Write(os.Stdout, format, r); err != nil {
+		log.Fatal("Failed to write results: %v", err)
+	}
+}
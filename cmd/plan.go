@@ -0,0 +1,60 @@
+package main
+
+import (
+	"time"
+
+	"github.com/mrinjamul/flareship/internal/log"
+	"github.com/mrinjamul/flareship/pkg/plan"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagOut string
+)
+
+// planCmd computes the changes sync would make and optionally saves them
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "show and save the changes sync would make",
+	Run: func(cmd *cobra.Command, args []string) {
+		log.Info("plan started...")
+
+		saved := plan.File{
+			CreatedAt:        time.Now().UTC(),
+			FlareshipVersion: Version,
+		}
+		for _, domain := range AppConfig.Domains {
+			if flagDomain != "" {
+				if flagDomain != domain.Name {
+					continue
+				}
+			}
+
+			log.Info("plan for %s ...", domain.Name)
+			cs, remote, err := planDomain(cmd.Context(), newClient(domain), domain)
+			if err != nil {
+				log.Fatal("%v", err)
+			}
+			log.Info("Plan for %s:", domain.Name)
+			log.Info("--------------------------------------------------------------------------------")
+			printChangeSet(cs)
+			log.Info("--------------------------------------------------------------------------------")
+			saved.Zones = append(saved.Zones, plan.NewZone(cs, enabledTypes(domain), remote))
+		}
+
+		if flagOut == "" {
+			return
+		}
+		if err := plan.WriteFile(flagOut, saved); err != nil {
+			log.Fatal("Failed to save plan: %v", err)
+		}
+		log.Info("plan saved to %s", flagOut)
+		log.Info("run `flareship apply %s` to apply exactly these changes", flagOut)
+	},
+}
+
+func init() {
+	planCmd.Flags().StringVarP(&flagOut, "out", "o", "", "save the plan to a file")
+	planCmd.Flags().BoolVar(&flagAdopt, "adopt", false, "claim unmanaged remote records in the record sets of local records")
+	planCmd.Flags().StringVar(&flagDomain, "domain", "", "specify the domain name")
+}
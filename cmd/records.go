@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mrinjamul/flareship/internal/cloudflare"
+	"github.com/mrinjamul/flareship/internal/log"
+	"github.com/mrinjamul/flareship/internal/utils"
+	"github.com/mrinjamul/flareship/pkg/plan"
+	"github.com/mrinjamul/flareship/pkg/schema"
+)
+
+// enabledTypes returns the record types managed for the domain
+func enabledTypes(domain schema.DomainConfig) []string {
+	// Set enabled records if it is null
+	if len(domain.RecordTypes) == 0 {
+		return []string{"A", "CNAME"}
+	}
+	return domain.RecordTypes
+}
+
+// localRecords reads the desired records of the domain from its records file.
+// Restricted subdomains are removed and names are completed with the domain.
+func localRecords(domain schema.DomainConfig, types []string) ([]schema.Record, error) {
+	logger := log.With(log.Fields{"domain": domain.Name})
+	logger.Info("gathering DNS Records from repository...")
+	entries, err := utils.GetDNSEntries(domain.RecordFile, types)
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse local DNS records: %w", err)
+	}
+	var records []schema.Record
+	for _, entry := range entries {
+		record := entry.Record
+		record.Comment = recordComment(entry)
+		record.Tags = recordTags(domain, entry)
+		records = append(records, record)
+	}
+	logger.Info("got %d local DNS Records in repo", len(records))
+
+	if domain.RestrictedFile != "" {
+		// remove restricted subdomains
+		logger.Info("removing restricted subdomains...")
+		var removed []schema.Record
+		records, removed = utils.RemoveRestrictedSubdomains(domain.RestrictedFile, records)
+		for _, r := range removed {
+			logger.With(log.Fields{"type": r.Type, "name": r.Name}).Debug("restricted %s record %s", r.Type, r.Name)
+		}
+		logger.Info("removed %d restricted subdomains", len(removed))
+	}
+
+	for id := range records {
+		// validate the record as written, like fmt --check
+		if err := records[id].Validate(); err != nil {
+			return nil, fmt.Errorf("invalid record in %s: %w", domain.RecordFile, err)
+		}
+		records[id].TTL = recordTTL(domain, records[id])
+		if records[id].Name == "@" {
+			records[id].Name = domain.Name
+		} else {
+			records[id].Name = records[id].Name + "." + domain.Name
+		}
+	}
+	return records, nil
+}
+
+// recordTTL returns the TTL of a local record: automatic if it is proxied, the
+// default TTL of the domain if it has none
+func recordTTL(domain schema.DomainConfig, record schema.Record) uint {
+	switch {
+	case record.Proxied:
+		// proxied records always have an automatic TTL
+		return schema.TTLAuto
+	case record.TTL != 0:
+		return record.TTL
+	case domain.DefaultTTL != 0:
+		return domain.DefaultTTL
+	}
+	return schema.TTLAuto
+}
+
+// planDomain computes the changes to bring the remote records of the domain
+// to the state of its records file. It also returns the remote records the
+// plan is based on.
+func planDomain(ctx context.Context, client *cloudflare.Client, domain schema.DomainConfig) (plan.ChangeSet, []schema.Record, error) {
+	types := enabledTypes(domain)
+
+	logger := log.With(log.Fields{"domain": domain.Name})
+
+	// gather from remote
+	logger.Info("gathering DNS Records from cloudflare api...")
+	remote, err := client.ReadAllRecords(ctx, domain.ZoneID, types)
+	if err != nil {
+		return plan.ChangeSet{}, nil, fmt.Errorf("fail to fetch remote DNS records: %w", err)
+	}
+	for _, r := range remote {
+		logger.With(log.Fields{"type": r.Type, "name": r.Name, "id": r.ID}).Debug("remote %s record %s %s", r.Type, r.Name, recordValue(r))
+	}
+	logger.Info("got %d registered DNS Records on cf", len(remote))
+
+	// gather from local
+	local, err := localRecords(domain, types)
+	if err != nil {
+		return plan.ChangeSet{}, nil, err
+	}
+
+	logger.Info("inspecting DNS records for differences..")
+	// only records owned by flareship are updated or deleted
+	planner := plan.Planner{Ownership: true, Adopt: flagAdopt}
+	cs := planner.Plan(local, remote)
+	cs.Domain = domain.Name
+	cs.ZoneID = domain.ZoneID
+	return cs, remote, nil
+}
+
+// printChangeSet prints the changes of a domain
+func printChangeSet(cs plan.ChangeSet) {
+	if creates := cs.Creates(); len(creates) > 0 {
+		log.Info("Records to be created:")
+		for _, c := range creates {
+			changeLog(cs.Domain, c).Info("+ %-10s %-30s %-40s", c.New.Type, c.New.Name, recordValue(*c.New))
+		}
+	}
+
+	if updates := cs.Updates(); len(updates) > 0 {
+		log.Info("Records to be updated:")
+		for _, c := range updates {
+			l := changeLog(cs.Domain, c)
+			l.Info("~ %-10s %-30s", c.New.Type, c.New.Name)
+			for _, f := range plan.Diff(*c.Old, *c.New) {
+				l.With(log.Fields{"field": f.Field, "old": f.Old, "new": f.New}).Info("    %-8s %s → %s", f.Field+":", orNone(f.Old), orNone(f.New))
+			}
+		}
+	}
+
+	if deletes := cs.Deletes(); len(deletes) > 0 {
+		log.Info("Records to be deleted:")
+		for _, c := range deletes {
+			changeLog(cs.Domain, c).Info("- %-10s %-30s %-40s", c.Old.Type, c.Old.Name, recordValue(*c.Old))
+		}
+	}
+
+	if cs.Resolved() {
+		log.Info("No differences found.")
+	}
+
+	if len(cs.Skipped) > 0 {
+		log.Warn("%d record(s) exist but are not managed by flareship, run with --adopt to claim them:", len(cs.Skipped))
+		for _, r := range cs.Skipped {
+			log.With(log.Fields{"domain": cs.Domain, "action": "skip", "type": r.Type, "name": r.Name}).Warn("  %-10s %-30s %-40s", r.Type, r.Name, recordValue(r))
+		}
+	}
+	unmanaged := len(cs.Unmanaged) - len(cs.Skipped)
+	if len(cs.Conflicts) > 0 {
+		log.Warn("%d record set(s) left unchanged, records not managed by flareship hold other values, run with --adopt to claim them:", len(cs.Conflicts))
+		for _, c := range cs.Conflicts {
+			r := c.Desired[0]
+			l := log.With(log.Fields{"domain": cs.Domain, "action": "conflict", "type": r.Type, "name": r.Name})
+			l.Warn("! %-10s %-30s", r.Type, r.Name)
+			l.Warn("    local:   %s", recordValues(c.Desired))
+			l.Warn("    remote:  %s", recordValues(c.Remote))
+			unmanaged -= len(c.Remote)
+		}
+	}
+	if unmanaged > 0 {
+		log.Info("%d unmanaged remote record(s) left alone", unmanaged)
+	}
+}
+
+// recordValues returns the values of the records as a list
+func recordValues(records []schema.Record) string {
+	var values []string
+	for _, r := range records {
+		values = append(values, recordValue(r))
+	}
+	return strings.Join(values, ", ")
+}
+
+// changeLog returns a logger with the fields of the change
+func changeLog(domain string, c plan.Change) *log.Entry {
+	r := c.Record()
+	return log.With(log.Fields{"domain": domain, "action": string(c.Action), "type": r.Type, "name": r.Name})
+}
+
+// checkSafety aborts the changes of a domain if they exceed the deletion
+// limits, and asks for confirmation of deletions on a terminal. remote is the
+// number of remote records the changes were planned against.
+func checkSafety(cs plan.ChangeSet, remote int) error {
+	// unmanaged records are never changed
+	remote -= len(cs.Unmanaged)
+	deletes := cs.Deletes()
+	if flagMaxDeletes >= 0 && len(deletes) > flagMaxDeletes {
+		return fmt.Errorf("%d record(s) would be deleted, more than --max-deletes=%d", len(deletes), flagMaxDeletes)
+	}
+	if changed := len(cs.Updates()) + len(deletes); flagMaxChangePercent > 0 && remote > 0 {
+		percent := float64(changed) * 100 / float64(remote)
+		if percent > flagMaxChangePercent {
+			return fmt.Errorf("%.1f%% of the %d remote record(s) would be changed or deleted, more than --max-change-percent=%g", percent, remote, flagMaxChangePercent)
+		}
+	}
+
+	if len(deletes) == 0 || flagYes || !utils.IsTerminal(os.Stdin) {
+		return nil
+	}
+	log.Info("The following record(s) will be deleted from %s:", cs.Domain)
+	for _, c := range deletes {
+		log.Info("- %-10s %-30s %-40s", c.Old.Type, c.Old.Name, recordValue(*c.Old))
+	}
+	if !utils.ConfirmPrompt(fmt.Sprintf("Delete %d record(s)?", len(deletes))) {
+		return fmt.Errorf("aborted, no changes were applied")
+	}
+	return nil
+}
+
+// applyChangeSet applies the changes of a domain, in a single batch request
+// with --atomic, and prints the status. The result holds the changes applied,
+// also on failure.
+func applyChangeSet(ctx context.Context, client *cloudflare.Client, cs plan.ChangeSet) (plan.Result, error) {
+	// atomic mode sends all changes as one batch request
+	atomic := flagAtomic
+	if atomic && client.BatchLimit > 0 && len(cs.Changes) > client.BatchLimit {
+		log.Warn("%d changes exceed the batch limit of %d, falling back to per-record changes (not atomic)", len(cs.Changes), client.BatchLimit)
+		atomic = false
+	}
+
+	var result plan.Result
+	var err error
+	if atomic {
+		log.Info("applying %d change(s) in a single batch...", len(cs.Changes))
+		result, err = plan.ApplyAtomic(ctx, client, cs)
+		if err != nil {
+			return result, fmt.Errorf("batch failed, no changes were applied: %w", err)
+		}
+	} else {
+		result, err = plan.Apply(ctx, client, cs)
+		if err != nil {
+			resultLog(cs.Domain, result).Warn("STATUS - %d record(s) created, %d record(s) updated, %d record(s) deleted before failure", result.Created, result.Updated, result.Deleted)
+			return result, err
+		}
+	}
+	resultLog(cs.Domain, result).Info("STATUS - %d record(s) created, %d record(s) updated, %d record(s) deleted", result.Created, result.Updated, result.Deleted)
+	return result, nil
+}
+
+// resultLog returns a logger with the counts of the applied changes
+func resultLog(domain string, result plan.Result) *log.Entry {
+	return log.With(log.Fields{"domain": domain, "created": result.Created, "updated": result.Updated, "deleted": result.Deleted})
+}
+
+// recordValue returns the value of the record for display, prefixed by its
+// priority for MX and URI records
+func recordValue(r schema.Record) string {
+	if r.Priority != nil {
+		return fmt.Sprintf("%d %s", *r.Priority, r.Value())
+	}
+	return r.Value()
+}
+
+// orNone returns the value for display, "(none)" if it is empty
+func orNone(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
+
+// recordComment generates the comment of a record from the metadata of its
+// entry, e.g. "managed-by:flareship owner=alice repo=github.com/alice/site".
+// The description, or the comment of the record, follows the metadata. The
+// comment is truncated to schema.MaxCommentLength.
+func recordComment(entry schema.Records) string {
+	parts := []string{plan.OwnerMarker}
+	if owner := entryOwner(entry); owner != "" {
+		parts = append(parts, "owner="+owner)
+	}
+	if entry.Repo != "" {
+		parts = append(parts, "repo="+entry.Repo)
+	}
+	note := entry.Record.Comment
+	if note == "" {
+		note = entry.Description
+	}
+	if note = strings.TrimSpace(strings.ReplaceAll(note, plan.OwnerMarker, "")); note != "" {
+		parts = append(parts, note)
+	}
+	comment := []rune(strings.Join(parts, " "))
+	if len(comment) > schema.MaxCommentLength {
+		comment = comment[:schema.MaxCommentLength]
+	}
+	return strings.TrimSpace(string(comment))
+}
+
+// recordTags returns the tags of the record followed by the tags of the
+// domain, with the metadata placeholders replaced. Tags are sorted and unique.
+func recordTags(domain schema.DomainConfig, entry schema.Records) []string {
+	replacer := strings.NewReplacer("{owner}", entryOwner(entry), "{repo}", entry.Repo)
+	seen := map[string]bool{}
+	var tags []string
+	for _, tag := range append(append([]string(nil), entry.Record.Tags...), domain.Tags...) {
+		tag = strings.TrimSpace(replacer.Replace(tag))
+		// drop tags whose placeholders had no value, e.g. "owner:"
+		if tag == "" || strings.HasSuffix(tag, ":") || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// entryOwner returns the username of the owner of the entry, or its email
+func entryOwner(entry schema.Records) string {
+	if entry.Owner.Username != "" {
+		return entry.Owner.Username
+	}
+	return entry.Owner.Email
+}
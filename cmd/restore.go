@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mrinjamul/flareship/internal/backup"
+	"github.com/mrinjamul/flareship/internal/log"
+	"github.com/mrinjamul/flareship/pkg/plan"
+	"github.com/mrinjamul/flareship/pkg/schema"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagNames string
+)
+
+// restoreCmd brings a zone back to the state of a backup
+var restoreCmd = &cobra.Command{
+	Use:   "restore <backup-file>",
+	Short: "restore DNS records from a backup",
+	Long: `Restore the records of a zone from a backup file written by backup.
+
+The changes are planned and shown like diff before they are applied. All
+records of the backed up types are restored, including records not managed by
+flareship. Use --name and --type to restore a subset.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		domain, err := restoreDomain(flagDomain)
+		if err != nil {
+			log.Fatal("%v", err)
+		}
+		b, err := backup.Read(args[0], domain.Name)
+		if err != nil {
+			log.Fatal("Failed to read backup: %v", err)
+		}
+		if err := restoreBackup(cmd.Context(), domain, b); err != nil {
+			log.Fatal("restore failed for %s: %v", domain.Name, err)
+		}
+	},
+}
+
+func init() {
+	restoreCmd.Flags().StringVar(&flagDomain, "domain", "", "specify the domain name")
+	restoreCmd.Flags().StringVar(&flagNames, "name", "", "restore only these record names, comma separated")
+	restoreCmd.Flags().StringVarP(&flagTypes, "type", "t", "", "restore only these record types, comma separated")
+	restoreCmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "show the changes without applying them")
+	restoreCmd.Flags().BoolVar(&flagAtomic, "atomic", false, "apply all changes in a single batch request")
+	addSafetyFlags(restoreCmd)
+}
+
+// restoreDomain returns the configured domain of the name, or the only
+// configured domain if name is empty
+func restoreDomain(name string) (schema.DomainConfig, error) {
+	for _, domain := range AppConfig.Domains {
+		if domain.Name == name || name == "" && len(AppConfig.Domains) == 1 {
+			return domain, nil
+		}
+	}
+	if name == "" {
+		return schema.DomainConfig{}, fmt.Errorf("%d domains are configured, use --domain", len(AppConfig.Domains))
+	}
+	return schema.DomainConfig{}, fmt.Errorf("domain %s is not configured", name)
+}
+
+// restoreBackup plans and applies the changes bringing the remote records of
+// the domain to the state of the backup, limited to --name and --type
+func restoreBackup(ctx context.Context, domain schema.DomainConfig, b *backup.Backup) error {
+	if b.ZoneID != "" && b.ZoneID != domain.ZoneID {
+		return fmt.Errorf("the backup is of zone %s (%s), not %s", b.Domain, b.ZoneID, domain.ZoneID)
+	}
+	types := b.Types
+	if len(types) == 0 {
+		types = enabledTypes(domain)
+	}
+	if flagTypes != "" {
+		types = strings.Split(strings.ToUpper(flagTypes), ",")
+	}
+	var names []string
+	if flagNames != "" {
+		for _, name := range strings.Split(flagNames, ",") {
+			names = append(names, strings.ToLower(qualifyName(strings.TrimSpace(name), domain.Name)))
+		}
+	}
+
+	client := newClient(domain)
+	log.Info("restore for %s ...", domain.Name)
+	log.Info("gathering DNS Records from cloudflare api...")
+	remote, err := client.ReadAllRecords(ctx, domain.ZoneID, types)
+	if err != nil {
+		return fmt.Errorf("fail to fetch remote DNS records: %w", err)
+	}
+
+	var desired []schema.Record
+	for _, record := range selectRecords(b.Records, types, names) {
+		// the backed up records are recreated if they were deleted
+		record.ID, record.CreatedOn, record.ModifiedOn = "", "", ""
+		desired = append(desired, record)
+	}
+	actual := selectRecords(remote, types, names)
+	log.Info("got %d DNS Records in the backup, %d on cf", len(desired), len(actual))
+
+	cs := plan.Planner{Exact: true}.Plan(desired, actual)
+	cs.Domain = domain.Name
+	cs.ZoneID = domain.ZoneID
+	log.Info("Restore of %s:", domain.Name)
+	log.Info("--------------------------------------------------------------------------------")
+	printChangeSet(cs)
+	log.Info("--------------------------------------------------------------------------------")
+
+	if flagDryRun {
+		log.Info("STATUS - dry run, %d record(s) to create, %d record(s) to update, %d record(s) to delete", len(cs.Creates()), len(cs.Updates()), len(cs.Deletes()))
+		return nil
+	}
+	if err := checkSafety(cs, len(actual)); err != nil {
+		return err
+	}
+	if _, err := applyChangeSet(ctx, client, cs); err != nil {
+		return err
+	}
+	log.Info("restore completed for %s 🎉", domain.Name)
+	return nil
+}
+
+// selectRecords returns the records of the types, and of the names if any
+func selectRecords(records []schema.Record, types, names []string) []schema.Record {
+	var selected []schema.Record
+	for _, record := range records {
+		if !containsFold(types, record.Type) {
+			continue
+		}
+		if len(names) > 0 && !containsFold(names, record.Name) {
+			continue
+		}
+		selected = append(selected, record)
+	}
+	return selected
+}
+
+// qualifyName completes a name relative to the domain, "@" is the apex
+func qualifyName(name, domain string) string {
+	switch {
+	case name == "@":
+		return domain
+	case name == domain || strings.HasSuffix(name, "."+domain):
+		return name
+	}
+	return name + "." + domain
+}
+
+// containsFold reports whether the list holds s, ignoring case
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/mrinjamul/flareship/internal/backup"
+	"github.com/mrinjamul/flareship/internal/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagLast bool
+)
+
+// rollbackCmd restores the snapshots taken by sync
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback [snapshot-id]",
+	Short: "restore the snapshot taken before a sync",
+	Long: `Restore the zones changed by a sync to the snapshot taken before it.
+
+Use --last for the latest snapshot, or give the snapshot ID printed by sync.
+The changes are shown like diff before they are applied.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 && !flagLast {
+			log.Fatal("specify a snapshot ID or --last")
+		}
+		dir := snapshotConfig().Dir
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			log.Fatal("no snapshots found: %v", err)
+		}
+
+		// snapshots of the selected domains, by snapshot ID
+		snapshots := map[string][]string{}
+		var last string
+		for _, entry := range entries {
+			zone, stamp, ok := backup.ParseName(entry.Name())
+			if !ok || entry.IsDir() || flagDomain != "" && zone != flagDomain {
+				continue
+			}
+			snapshots[stamp] = append(snapshots[stamp], entry.Name())
+			if stamp > last {
+				last = stamp
+			}
+		}
+		id := last
+		if len(args) > 0 {
+			id = args[0]
+		}
+		if len(snapshots[id]) == 0 {
+			log.Fatal("snapshot %q not found in %s", id, dir)
+		}
+
+		log.Info("rolling back to snapshot %s ...", id)
+		var failed []string
+		for _, name := range snapshots[id] {
+			zone, _, _ := backup.ParseName(name)
+			domain, err := restoreDomain(zone)
+			if err != nil {
+				log.With(log.Fields{"domain": zone, "error": err}).Error("%v", err)
+				failed = append(failed, zone)
+				continue
+			}
+			b, err := backup.Read(filepath.Join(dir, name), domain.Name)
+			if err != nil {
+				log.With(log.Fields{"domain": zone, "error": err}).Error("%v", err)
+				failed = append(failed, zone)
+				continue
+			}
+			if err := restoreBackup(cmd.Context(), domain, b); err != nil {
+				log.With(log.Fields{"domain": domain.Name, "error": err}).Error("rollback failed for %s: %v", domain.Name, err)
+				failed = append(failed, zone)
+			}
+		}
+		if len(failed) > 0 {
+			log.With(log.Fields{"failed": failed}).Error("rollback failed for %d domain(s): %v", len(failed), failed)
+		}
+	},
+}
+
+func init() {
+	rollbackCmd.Flags().BoolVar(&flagLast, "last", false, "roll back the latest snapshot")
+	rollbackCmd.Flags().StringVar(&flagDomain, "domain", "", "specify the domain name")
+	rollbackCmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "show the changes without applying them")
+	rollbackCmd.Flags().BoolVar(&flagAtomic, "atomic", false, "apply all changes of a domain in a single batch request")
+	addSafetyFlags(rollbackCmd)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/mrinjamul/flareship/internal/backup"
+	"github.com/mrinjamul/flareship/internal/log"
+	"github.com/mrinjamul/flareship/pkg/schema"
+)
+
+const (
+	// defaultSnapshotDir is where snapshots are written unless configured
+	defaultSnapshotDir = ".flareship/snapshots"
+	// defaultSnapshotKeep is the number of snapshots kept per domain
+	defaultSnapshotKeep = 20
+)
+
+var (
+	flagNoSnapshot bool
+	// snapshotAt is the time of the snapshots of this run, all zones changed
+	// by one sync share it, it is their snapshot ID
+	snapshotAt time.Time
+)
+
+// snapshotConfig returns the snapshot settings with the defaults applied
+func snapshotConfig() schema.SnapshotConfig {
+	var cfg schema.SnapshotConfig
+	if AppConfig.Snapshots != nil {
+		cfg = *AppConfig.Snapshots
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = defaultSnapshotDir
+	}
+	if cfg.Keep == 0 {
+		cfg.Keep = defaultSnapshotKeep
+	}
+	return cfg
+}
+
+// takeSnapshot saves the remote records of the domain before changes are
+// applied, in the backup format. It returns the snapshot ID, empty if
+// snapshots are disabled.
+func takeSnapshot(domain schema.DomainConfig, types []string, remote []schema.Record) (string, error) {
+	cfg := snapshotConfig()
+	if cfg.Disabled || flagNoSnapshot {
+		return "", nil
+	}
+	if snapshotAt.IsZero() {
+		snapshotAt = newSnapshotTime(cfg.Dir)
+	}
+	b := &backup.Backup{
+		Version:          backup.Version,
+		CreatedAt:        snapshotAt,
+		FlareshipVersion: Version,
+		Domain:           domain.Name,
+		ZoneID:           domain.ZoneID,
+		Types:            types,
+		Records:          remote,
+	}
+	path := filepath.Join(cfg.Dir, backup.Name(domain.Name, snapshotAt, false))
+	// an existing snapshot is never replaced, it may be the only copy of the
+	// records before an earlier sync
+	if err := backup.CreateFile(path, b); err != nil {
+		return "", err
+	}
+	log.Info("snapshot of %d record(s) saved to %s", len(remote), path)
+	if _, err := backup.Prune(cfg.Dir, domain.Name, cfg.Keep); err != nil {
+		log.Warn("failed to remove old snapshots: %v", err)
+	}
+	return backup.Stamp(snapshotAt), nil
+}
+
+// newSnapshotTime returns the current time, moved forward past the snapshot
+// IDs already taken in dir so that a quick second sync gets its own ID
+func newSnapshotTime(dir string) time.Time {
+	at := time.Now().UTC()
+	for {
+		taken, _ := filepath.Glob(filepath.Join(dir, "dns_records_*_"+backup.Stamp(at)+".json*"))
+		if len(taken) == 0 {
+			return at
+		}
+		at = at.Add(time.Millisecond)
+	}
+}
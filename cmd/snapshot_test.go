@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mrinjamul/flareship/internal/backup"
+	"github.com/mrinjamul/flareship/pkg/schema"
+)
+
+func TestTakeSnapshotBackToBack(t *testing.T) {
+	dir := t.TempDir()
+	AppConfig = &schema.AppConfig{Snapshots: &schema.SnapshotConfig{Dir: dir}}
+	t.Cleanup(func() {
+		AppConfig = nil
+		snapshotAt = time.Time{}
+	})
+	domain := schema.DomainConfig{Name: "example.com", ZoneID: "z1"}
+
+	// two syncs, each with its own snapshot time, within the same second
+	var ids []string
+	for _, ttl := range []uint{600, 900} {
+		snapshotAt = time.Time{}
+		id, err := takeSnapshot(domain, []string{"A"}, []schema.Record{
+			{Type: "A", Name: "www.example.com", Content: "1.1.1.1", TTL: ttl},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if ids[0] == ids[1] {
+		t.Fatalf("both snapshots have the ID %s", ids[0])
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d snapshot files, want 2", len(entries))
+	}
+	first, err := backup.Read(filepath.Join(dir, backup.Name(domain.Name, mustParseStamp(t, ids[0]), false)), domain.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Records) != 1 || first.Records[0].TTL != 600 {
+		t.Fatalf("first snapshot holds %+v, want the record with TTL 600", first.Records)
+	}
+
+	// an existing snapshot is never replaced
+	_, err = takeSnapshot(domain, []string{"A"}, nil)
+	if !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("got error %v for an existing snapshot, want fs.ErrExist", err)
+	}
+}
+
+// mustParseStamp parses a snapshot ID
+func mustParseStamp(t *testing.T, id string) time.Time {
+	t.Helper()
+	at, err := time.Parse("20060102T150405.000Z", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return at
+}
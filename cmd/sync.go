@@ -1,23 +1,26 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"fmt"
 
-	"github.com/mrinjamul/flareship/internal/cloudflare"
+	"github.com/mrinjamul/flareship/internal/backup"
 	"github.com/mrinjamul/flareship/internal/log" // Import the new log package
-	"github.com/mrinjamul/flareship/internal/utils"
+	"github.com/mrinjamul/flareship/internal/report"
+	"github.com/mrinjamul/flareship/pkg/plan"
 	"github.com/mrinjamul/flareship/pkg/schema"
 	"github.com/spf13/cobra"
 )
 
 var (
-	flagDryRun bool
-)
-
-var (
-	// EnabledRecordType []string = []string{"A", "AAAA", "CNAME", "TXT", "MX", "SRV"}
-	// EnabledRecordType specifies the record types that will be synced
-	EnabledRecordType []string = []string{}
+	flagDryRun           bool
+	flagAtomic           bool
+	flagAdopt            bool
+	flagYes              bool
+	flagMaxDeletes       int
+	flagMaxChangePercent float64
+	// snapshots are the domains snapshotted by this sync
+	snapshots []string
 )
 
 // Sync sync the records
@@ -25,159 +28,102 @@ var syncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "sync with remote DNS.",
 	Run: func(cmd *cobra.Command, args []string) {
+		format := outputFormat()
+		results := report.NewChanges()
 
 		log.Info("flareship CLI is running 🌟")
 		log.Info("sync started...")
 
+		var failed []string
 		for _, domain := range AppConfig.Domains {
 			if flagDomain != "" {
 				if flagDomain != domain.Name {
 					continue
 				}
 			}
-			// Set domain name if flag exist
-			domainName := domain.Name
-			recordsFile := domain.RecordFile
-			restrictedFile := domain.RestrictedFile
-			zoneID := domain.ZoneID
-			token := domain.CFToken
-
-			// Set enabled records if it is null
-			if len(domain.RecordTypes) == 0 {
-				EnabledRecordType = []string{"A", "CNAME"}
-			} else {
-				EnabledRecordType = domain.RecordTypes
-			}
-
-			log.Info("sync for %s ...", domainName)
-
-			// gather from remote
-			log.Info("gathering DNS Records from cloudflare api...")
-			registeredRecords := cloudflare.ReadAllRecords(zoneID, token, EnabledRecordType)
-			log.Info("got %d registered DNS Records on cf", len(registeredRecords))
-			// gather from local
-			log.Info("gathering DNS Records from repository...")
-			localRecords, err := utils.GetDNSRecords(recordsFile, EnabledRecordType)
-			if err != nil {
-				log.Error("fail to parse local DNS records: %v", err)
-			}
-			for id := range localRecords {
-				localRecords[id].TTL = 1
-				if localRecords[id].Name == "@" {
-					localRecords[id].Name = domainName
-				} else {
-					localRecords[id].Name = localRecords[id].Name + "." + domainName
-				}
-			}
-			log.Info("got %d local CNAME Records in repo", len(localRecords))
-
-			// remove restricted subdomains
-			log.Info("removing restricted subdomains...")
-			localRecords, removedRecords := utils.RemoveRestrictedSubdomains(restrictedFile, localRecords)
-			log.Info("got %d local CNAME Records after removing restricted subdomains", len(localRecords))
-			log.Info("removed %d restricted subdomains", len(removedRecords))
-
-			var createdRecords []schema.Record
-			var updatedRecords []schema.Record
-
-			log.Info("inspecting DNS records ..")
-
-			for _, record := range localRecords {
-				r := utils.FindRecordByName(registeredRecords, record.Name)
-				if r.ID != "" {
-					if r.Content != record.Content || r.Proxied != record.Proxied || r.Name != record.Name {
-						record.ID = r.ID
-						updatedRecords = append(updatedRecords, record)
-					}
-				} else {
-					createdRecords = append(createdRecords, record)
-				}
-			}
-			log.Info("found %d DNS Records to create", len(createdRecords))
-			log.Info("found %d DNS Records to update", len(updatedRecords))
-
-			// Create records from the list
-			if len(createdRecords) > 0 {
-				log.Info("Creating DNS Record(s):")
-				for _, r := range createdRecords {
-					postBody, err := json.Marshal(r)
-					if err != nil {
-						log.Error("fail to marshal record while creating: %v", err)
-					}
-					if !flagDryRun {
-						newRecords := cloudflare.CreateRecord(zoneID, token, postBody)
-						r = newRecords
-					}
-					log.Info("+ %-10s %-30s %-40s", r.Type, r.Name, r.Content)
-				}
-			}
-			// Update records from the list
-			if len(updatedRecords) > 0 {
-				log.Info("Updating DNS Record(s):")
-				for _, newRecord := range updatedRecords {
-					// Find the old record from registeredRecords
-					var oldRecord schema.Record
-					for _, regRec := range registeredRecords {
-						if regRec.Name == newRecord.Name && regRec.Type == newRecord.Type {
-							oldRecord = regRec
-							break
-						}
-					}
-
-					log.Info("~ %-10s %-30s", newRecord.Type, newRecord.Name)
-					if oldRecord.Content != newRecord.Content {
-						log.Info("- %-40s", oldRecord.Content)
-						log.Info("+ %-40s", newRecord.Content)
-					}
-					if oldRecord.Proxied != newRecord.Proxied {
-						log.Info("- Proxied: %t", oldRecord.Proxied)
-						log.Info("+ Proxied: %t", newRecord.Proxied)
-					}
-
-					postBody, err := json.Marshal(newRecord)
-					if err != nil {
-						log.Error("fail to marshal record while updating: %v", err)
-					}
-					if !flagDryRun {
-						cloudflare.UpdateRecord(zoneID, token, newRecord.ID, postBody)
-					}
-				}
+			if err := syncDomain(cmd.Context(), domain, results); err != nil {
+				log.With(log.Fields{"domain": domain.Name, "error": err}).Error("sync failed for %s: %v", domain.Name, err)
+				failed = append(failed, domain.Name)
+				continue
 			}
-			// check for unused records
-			log.Info("checking for deleted DNS records...") // Replaced fmt.Println
-			var deletedRecords []schema.Record
-			// check record which is not in the registeredRecords
-			for _, r := range registeredRecords {
-				if !utils.RecordContain(localRecords, r) {
-					deletedRecords = append(deletedRecords, r)
-				}
-			}
-			log.Info("found %d DNS Records to be delete", len(deletedRecords)) // Replaced fmt.Printf
-			// Delete unsed records
-			if len(deletedRecords) != 0 {
-				log.Info("Deleting DNS Record:") // Replaced fmt.Println
-				for _, r := range deletedRecords {
-					var result schema.DelResponse
-					if !flagDryRun {
-						result = cloudflare.DeleteRecord(zoneID, token, r.ID)
-						if result.Result.ID == "" {
-							log.Error("failed to delete %s:%s", r.Type, r.Name) // Replaced fmt.Println and os.Exit(1)
-						}
-					}
-					log.Info("- %-10s %-30s %-40s", r.Type, r.Name, r.Content)
-				}
-			} else {
-				log.Info("found none") // Replaced fmt.Println
-			}
-			log.Info("STATUS - %d record(s) created, %d record(s) updated, %d record(s) deleted", len(createdRecords), len(updatedRecords), len(deletedRecords)) // Replaced fmt.Printf
-			
-			log.Info("sync completed for %s 🎉", domainName) // Replaced fmt.Printf
 		}
-
+		writeReport(format, results)
+		if len(snapshots) > 0 {
+			log.Info("SNAPSHOT - %s of %v, run `flareship rollback --last` to undo", backup.Stamp(snapshotAt), snapshots)
+		}
+		if len(failed) > 0 {
+			log.With(log.Fields{"failed": failed}).Error("sync failed for %d domain(s): %v", len(failed), failed)
+		}
 	},
 }
 
 func init() {
 	syncCmd.Flags().BoolVar(&flagDryRun, "dry-run", false, "dry run the sync")
+	syncCmd.Flags().BoolVar(&flagAtomic, "atomic", false, "apply all changes of a domain in a single batch request")
+	addSafetyFlags(syncCmd)
+	syncCmd.Flags().BoolVar(&flagNoSnapshot, "no-snapshot", false, "do not snapshot the remote records before changing them")
+	syncCmd.Flags().BoolVar(&flagAdopt, "adopt", false, "claim unmanaged remote records in the record sets of local records")
 	syncCmd.Flags().StringVar(&flagDomain, "domain", "", "specify the domain name")
-}
\ No newline at end of file
+	addOutputFlag(syncCmd)
+}
+
+// syncDomain syncs the records of a single domain and adds the outcome to the
+// results. It stops at the first failed API call and reports how many changes
+// were applied before it.
+func syncDomain(ctx context.Context, domain schema.DomainConfig, results *report.Changes) error {
+	client := newClient(domain)
+	logger := log.With(log.Fields{"domain": domain.Name})
+	logger.Info("sync for %s ...", domain.Name)
+
+	cs, remote, err := planDomain(ctx, client, domain)
+	if err != nil {
+		results.Fail(domain.Name, err)
+		return err
+	}
+	result := results.Add(cs)
+	logger.Info("found %d DNS Records to create", len(cs.Creates()))
+	logger.Info("found %d DNS Records to update", len(cs.Updates()))
+	logger.Info("found %d DNS Records to be delete", len(cs.Deletes()))
+	printChangeSet(cs)
+
+	if flagDryRun {
+		logger.With(log.Fields{"dry_run": true, "created": len(cs.Creates()), "updated": len(cs.Updates()), "deleted": len(cs.Deletes())}).Info("STATUS - dry run, %d record(s) to create, %d record(s) to update, %d record(s) to delete", len(cs.Creates()), len(cs.Updates()), len(cs.Deletes()))
+		return nil
+	}
+
+	if err := checkSafety(cs, len(remote)); err != nil {
+		result.Apply(plan.Result{}, err)
+		return err
+	}
+	if !cs.Empty() {
+		id, err := takeSnapshot(domain, enabledTypes(domain), remote)
+		if err != nil {
+			err = fmt.Errorf("failed to snapshot the records, no changes were applied: %w", err)
+			result.Apply(plan.Result{}, err)
+			return err
+		}
+		if id != "" {
+			snapshots = append(snapshots, domain.Name)
+			result.Snapshot = id
+		}
+	}
+	applied, err := applyChangeSet(ctx, client, cs)
+	if err == nil && len(cs.Conflicts) > 0 {
+		// the other changes are applied, but the domain is not in sync
+		err = fmt.Errorf("%d record set(s) left unchanged because of records not managed by flareship, run with --adopt to claim them", len(cs.Conflicts))
+	}
+	result.Apply(applied, err)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("sync completed for %s 🎉", domain.Name)
+	return nil
+}
+
+// addSafetyFlags adds the flags guarding against mass deletions
+func addSafetyFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&flagMaxDeletes, "max-deletes", -1, "abort if more records would be deleted, -1 for no limit")
+	cmd.Flags().Float64Var(&flagMaxChangePercent, "max-change-percent", 0, "abort if a higher percentage of remote records would be changed or deleted, 0 for no limit")
+	cmd.Flags().BoolVarP(&flagYes, "yes", "y", false, "do not ask for confirmation of deletions")
+}
@@ -2,7 +2,11 @@ module github.com/mrinjamul/flareship
 
 go 1.20
 
-require github.com/spf13/cobra v1.3.0
+require (
+	github.com/BurntSushi/toml v1.3.2
+	github.com/spf13/cobra v1.3.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/inconshreveable/mousetrap v1.0.0 // indirect
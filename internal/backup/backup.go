@@ -0,0 +1,260 @@
+// Package backup reads and writes the backups of the records of a zone
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mrinjamul/flareship/pkg/schema"
+)
+
+// Version is the version of the backup format written by WriteFile
+const Version = 1
+
+// Backup is the content of a backup file. Records are kept as returned by the
+// api, with all their fields.
+type Backup struct {
+	Version          int       `json:"version"`
+	CreatedAt        time.Time `json:"created_at"`
+	FlareshipVersion string    `json:"flareship_version,omitempty"`
+	// Domain and ZoneID identify the zone, they are empty for legacy backups
+	Domain string `json:"zone_name"`
+	ZoneID string `json:"zone_id"`
+	// Types are the record types the backup was taken of, nil if unknown
+	Types []string `json:"types,omitempty"`
+	// Records have fully qualified names
+	Records []schema.Record `json:"records"`
+}
+
+// Read reads a backup file of the domain, gzip-compressed or not. Legacy
+// backups are a list of records file entries with names relative to the
+// domain, the domain is needed to complete them.
+func Read(path, domain string) (*Backup, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid backup %s: %w", path, err)
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("invalid backup %s: %w", path, err)
+		}
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var b Backup
+		if err := json.Unmarshal(data, &b); err != nil {
+			return nil, fmt.Errorf("invalid backup %s: %w", path, err)
+		}
+		if b.Version > Version {
+			return nil, fmt.Errorf("backup %s has version %d, this flareship reads up to %d", path, b.Version, Version)
+		}
+		if domain != "" && b.Domain != "" && b.Domain != domain {
+			return nil, fmt.Errorf("backup %s is of %s, not %s", path, b.Domain, domain)
+		}
+		return &b, nil
+	}
+
+	var entries []schema.Records
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid backup %s: %w", path, err)
+	}
+	if domain == "" {
+		return nil, fmt.Errorf("the domain of the legacy backup %s is unknown", path)
+	}
+	b := &Backup{}
+	for _, entry := range entries {
+		record := entry.Record
+		record.Name = qualify(record.Name, domain)
+		b.Records = append(b.Records, record)
+	}
+	return b, nil
+}
+
+// WriteFile writes the backup as indented JSON, gzip-compressed if the path
+// ends with ".gz". The file is replaced atomically.
+func WriteFile(path string, b *Backup) error {
+	return write(path, b, os.Rename)
+}
+
+// CreateFile writes the backup like WriteFile, but fails with an error
+// matching fs.ErrExist instead of replacing an existing file
+func CreateFile(path string, b *Backup) error {
+	return write(path, b, os.Link)
+}
+
+// write writes the backup to a temporary file and moves it to path with
+// place
+func write(path string, b *Backup, place func(tmp, path string) error) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal backup: %w", err)
+	}
+	data = append(data, '\n')
+	if strings.HasSuffix(path, ".gz") {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".backup-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return place(tmp.Name(), path)
+}
+
+// Name returns the file name of a backup of the zone taken at the given
+// time, e.g. "dns_records_example.com_20240102T150405.123Z.json"
+func Name(zone string, at time.Time, compress bool) string {
+	name := prefix(zone) + Stamp(at) + ".json"
+	if compress {
+		name += ".gz"
+	}
+	return name
+}
+
+// Stamp formats the time as in backup names, e.g. "20240102T150405.123Z".
+// Milliseconds keep the names of backups taken in the same second apart.
+func Stamp(at time.Time) string {
+	return at.UTC().Format("20060102T150405.000Z")
+}
+
+// ParseName returns the zone and stamp of a backup file name
+func ParseName(name string) (zone, stamp string, ok bool) {
+	name = strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ".json")
+	if !strings.HasPrefix(name, "dns_records_") {
+		return "", "", false
+	}
+	name = strings.TrimPrefix(name, "dns_records_")
+	i := strings.LastIndex(name, "_")
+	if i < 0 {
+		return "", "", false
+	}
+	return name[:i], name[i+1:], true
+}
+
+// Path returns where to write a backup of the zone. output is a file, or a
+// directory if it exists as one or ends with a separator. The current
+// directory is used if output is empty.
+func Path(output, zone string, at time.Time, compress bool) string {
+	if output == "" {
+		output = "."
+	}
+	if IsDir(output) {
+		return filepath.Join(output, Name(zone, at, compress))
+	}
+	if compress && !strings.HasSuffix(output, ".gz") {
+		output += ".gz"
+	}
+	return output
+}
+
+// IsDir reports whether the output of a backup is a directory, because it
+// exists as one or ends with a separator
+func IsDir(output string) bool {
+	if strings.HasSuffix(output, string(os.PathSeparator)) {
+		return true
+	}
+	info, err := os.Stat(output)
+	return err == nil && info.IsDir()
+}
+
+// List returns the backups of the zone in dir, oldest first
+func List(dir, zone string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type backupFile struct {
+		path    string
+		modTime time.Time
+	}
+	var files []backupFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix(zone)) {
+			continue
+		}
+		if !strings.HasSuffix(name, ".json") && !strings.HasSuffix(name, ".json.gz") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, backupFile{path: filepath.Join(dir, name), modTime: info.ModTime()})
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if !files[i].modTime.Equal(files[j].modTime) {
+			return files[i].modTime.Before(files[j].modTime)
+		}
+		return files[i].path < files[j].path
+	})
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.path)
+	}
+	return paths, nil
+}
+
+// Prune removes the oldest backups of the zone in dir, keeping the newest
+// keep ones. It returns the removed files.
+func Prune(dir, zone string, keep int) ([]string, error) {
+	paths, err := List(dir, zone)
+	if err != nil || len(paths) <= keep {
+		return nil, err
+	}
+	var removed []string
+	for _, path := range paths[:len(paths)-keep] {
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// prefix is the start of the file names of the backups of the zone
+func prefix(zone string) string {
+	return "dns_records_" + zone + "_"
+}
+
+// qualify completes a name relative to the domain
+func qualify(name, domain string) string {
+	switch {
+	case name == "@" || name == "":
+		return domain
+	case name == domain || strings.HasSuffix(name, "."+domain):
+		return name
+	}
+	return name + "." + domain
+}
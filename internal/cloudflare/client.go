@@ -2,199 +2,242 @@ package cloudflare
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
-	"os"
-	"strconv"
+	"time"
 
 	"github.com/mrinjamul/flareship/internal/utils"
 	"github.com/mrinjamul/flareship/pkg/schema"
 )
 
-var (
-	// BaseAPI is the base url for cloudflare api
-	BaseAPI string = "https://api.cloudflare.com/client/v4/"
+const (
+	// DefaultBaseURL is the base url for cloudflare api
+	DefaultBaseURL = "https://api.cloudflare.com/client/v4/"
+	// DefaultTimeout is the maximum duration of a single API request
+	DefaultTimeout = 30 * time.Second
+	// DefaultUserAgent is sent with every request unless overridden
+	DefaultUserAgent = "flareship"
+	// DefaultBatchLimit is the number of changes allowed in a single batch
+	// request on the free plan, paid plans allow more
+	DefaultBatchLimit = 200
 )
 
+// Client is a Cloudflare API client bound to a single API token
+type Client struct {
+	// BaseURL is the API root, e.g. DefaultBaseURL or a local test server
+	BaseURL string
+	// Token is the API token used as Bearer authorization
+	Token string
+	// UserAgent is sent as the User-Agent header
+	UserAgent string
+	// HTTPClient is used to send requests, it can be replaced for testing
+	HTTPClient *http.Client
+	// Retry controls retries of failed idempotent requests
+	Retry RetryPolicy
+	// Limiter throttles requests, nil disables throttling
+	Limiter *RateLimiter
+	// BatchLimit is the maximum number of changes in one BatchRecords call
+	BatchLimit int
+}
+
+// NewClient returns a client for the given token with default settings
+func NewClient(token string) *Client {
+	return &Client{
+		BaseURL:    DefaultBaseURL,
+		Token:      token,
+		UserAgent:  DefaultUserAgent,
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+		Retry:      DefaultRetryPolicy(),
+		Limiter:    NewRateLimiter(DefaultRateLimit, DefaultRateLimitWindow),
+		BatchLimit: DefaultBatchLimit,
+	}
+}
+
 // ReadRecord creates a GET request
-func ReadRecord(zoneID, query, token string) (schema.CFResponse, error) {
+func (c *Client) ReadRecord(ctx context.Context, zoneID, query string) (schema.CFResponse, error) {
 	var result schema.CFResponse
 	endpoint := "zones/" + zoneID + "/dns_records?" + query
-	url := BaseAPI + endpoint
-	// Create a Bearer string by appending string access token
-	bearer := "Bearer " + token
-	// Create a new request using http
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		fmt.Println(err)
-	}
-	// add authorization header to the req
-	req.Header.Add("Authorization", bearer)
-	// Send req using http Client
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Println("Error on response.\nERROR -", err)
-	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Println("Error while reading the response bytes:", err)
-	}
-	err = json.Unmarshal(body, &result)
-	if err != nil {
-		fmt.Println(body)
-		fmt.Println("Error while parsing the response bytes:", err)
-	}
-	if len(result.Errors) > 0 {
-		return schema.CFResponse{}, fmt.Errorf("%s", result.Errors[0].Message)
+	if err := c.do(ctx, http.MethodGet, endpoint, nil, &result); err != nil {
+		return schema.CFResponse{}, err
 	}
 	return result, nil
 }
 
-// GetRecords returns all records from cloudflare api
-func ReadAllRecords(zoneID, token string, recordTypes []string) []schema.Record {
-	query := url.Values{}
+// ReadAllRecords returns all records of the given types from cloudflare api
+func (c *Client) ReadAllRecords(ctx context.Context, zoneID string, recordTypes []string) ([]schema.Record, error) {
 	var records []schema.Record
-	var results []schema.Result
-	for _, t := range recordTypes {
-		query.Add("type", t)
-		perPage := 100
-		page := 1
-		query.Add("per_page", strconv.Itoa(perPage))
-		for ok := true; ok; ok = (len(results) == perPage) {
-			query.Add("page", strconv.Itoa(page))
-			query := query.Encode()
-			resp, err := ReadRecord(zoneID, query, token)
-			if err != nil {
-				fmt.Println(err)
-				fmt.Println("ERROR - fail to fetch records")
-				os.Exit(1)
-			}
-			if !resp.Success {
-				break
-			}
-			results = resp.Result
-			records = utils.Concat(records, results)
-		}
-		query.Del("type")
+	it := c.Records(ctx, zoneID, recordTypes)
+	for it.Next() {
+		records = append(records, it.Record())
 	}
-	return records
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
 }
 
 // CreateRecord create a new record
-func CreateRecord(zoneID, token string, postBody []byte) schema.Record {
-	var result schema.Result
+func (c *Client) CreateRecord(ctx context.Context, zoneID string, record schema.Record) (schema.Record, error) {
+	var resp schema.PostResponse
 	endpoint := "zones/" + zoneID + "/dns_records"
-	resp, err := httpPost("POST", endpoint, postBody, token)
-	if err != nil {
-		fmt.Println(err)
-		fmt.Println("ERROR - fail to create records")
-		os.Exit(1)
+	if err := c.post(ctx, http.MethodPost, endpoint, record, &resp); err != nil {
+		return schema.Record{}, err
 	}
-	result = resp.Result
-	record := utils.ConcatOne(schema.Record{}, result)
-	return record
+	return utils.ConcatOne(schema.Record{}, resp.Result), nil
 }
 
 // UpdateRecord updates a record
-func UpdateRecord(zoneID, token, recordID string, postBody []byte) schema.Record {
-	var result schema.Result
+func (c *Client) UpdateRecord(ctx context.Context, zoneID, recordID string, record schema.Record) (schema.Record, error) {
+	var resp schema.PostResponse
 	endpoint := "zones/" + zoneID + "/dns_records/" + recordID
-	resp, err := httpPost("PUT", endpoint, postBody, token)
-	if err != nil {
-		fmt.Println(err)
-		fmt.Println("ERROR - fail to update records")
-		os.Exit(1)
+	if err := c.post(ctx, http.MethodPut, endpoint, record, &resp); err != nil {
+		return schema.Record{}, err
 	}
-	result = resp.Result
-	record := utils.ConcatOne(schema.Record{}, result)
-	return record
+	return utils.ConcatOne(schema.Record{}, resp.Result), nil
 }
 
 // DeleteRecord delete a record
-func DeleteRecord(zoneID, token, recordID string) schema.DelResponse {
+func (c *Client) DeleteRecord(ctx context.Context, zoneID, recordID string) error {
+	var resp schema.DelResponse
 	endpoint := "zones/" + zoneID + "/dns_records/" + recordID
-	resp, err := httpDelete(endpoint, token)
-	if err != nil {
-		fmt.Println(err)
-		fmt.Println("ERROR - fail to delete records")
-		os.Exit(1)
-	}
-	return resp
+	return c.do(ctx, http.MethodDelete, endpoint, nil, &resp)
 }
 
-// httpPost creates a POST or PUT or PATCH request
-func httpPost(method string, endpoint string, postBody []byte, token string) (schema.PostResponse, error) {
-	var result schema.PostResponse
-	if method == "" {
-		method = "POST"
-	}
-	url := BaseAPI + endpoint
-	responseBody := bytes.NewBuffer(postBody)
-	// Create a Bearer string by appending string access token
-	bearer := "Bearer " + token
-	// Create a new request using http
-	req, err := http.NewRequest(method, url, responseBody)
+// BatchRecords applies all changes of the batch atomically. Either every
+// change is applied or none is.
+func (c *Client) BatchRecords(ctx context.Context, zoneID string, batch schema.BatchRequest) (schema.BatchResult, error) {
+	if c.BatchLimit > 0 && batch.Len() > c.BatchLimit {
+		return schema.BatchResult{}, fmt.Errorf("%w: %d changes, limit is %d", ErrBatchTooLarge, batch.Len(), c.BatchLimit)
+	}
+	var resp schema.BatchResponse
+	endpoint := "zones/" + zoneID + "/dns_records/batch"
+	batch.Patches = payloads(batch.Patches)
+	batch.Puts = payloads(batch.Puts)
+	batch.Posts = payloads(batch.Posts)
+	postBody, err := json.Marshal(batch)
 	if err != nil {
-		fmt.Println(err)
+		return schema.BatchResult{}, fmt.Errorf("failed to marshal batch: %w", err)
 	}
-	// add authorization header to the req
-	req.Header.Add("Authorization", bearer)
-	// Send req using http Client
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Println("Error on response.\nERROR -", err)
+	if err := c.do(ctx, http.MethodPost, endpoint, postBody, &resp); err != nil {
+		return schema.BatchResult{}, err
 	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	return resp.Result, nil
+}
+
+// post marshals the record and sends it with a POST, PUT or PATCH request
+func (c *Client) post(ctx context.Context, method, endpoint string, record schema.Record, resp *schema.PostResponse) error {
+	postBody, err := json.Marshal(payload(record))
 	if err != nil {
-		fmt.Println("Error while reading the response bytes:", err)
+		return fmt.Errorf("failed to marshal record: %w", err)
 	}
-	err = json.Unmarshal(body, &result)
-	if err != nil {
-		fmt.Println("Error while parsing the response bytes:", err)
+	return c.do(ctx, method, endpoint, postBody, resp)
+}
+
+// payload clears the read-only fields of the record before sending it
+func payload(record schema.Record) schema.Record {
+	record.CreatedOn = ""
+	record.ModifiedOn = ""
+	return record
+}
+
+// payloads clears the read-only fields of the records
+func payloads(records []schema.Record) []schema.Record {
+	var result []schema.Record
+	for _, record := range records {
+		result = append(result, payload(record))
 	}
-	if len(result.Errors) > 0 {
-		return schema.PostResponse{}, fmt.Errorf("%s", result.Errors[0].Message)
+	return result
+}
+
+// envelope is the part of the response shared by every endpoint
+type envelope struct {
+	Success bool            `json:"success"`
+	Errors  []schema.Errors `json:"errors"`
+}
+
+// do sends a request to the endpoint and decodes the JSON response into out.
+// Idempotent requests are retried according to the client's RetryPolicy.
+// It returns an *APIError if the response is not successful.
+func (c *Client) do(ctx context.Context, method, endpoint string, body []byte, out interface{}) error {
+	for attempt := 0; ; attempt++ {
+		if err := c.Limiter.Wait(ctx); err != nil {
+			return err
+		}
+		err := c.send(ctx, method, endpoint, body, out)
+		if err == nil || attempt >= c.Retry.MaxRetries || !idempotent(method) || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		var retryAfter time.Duration
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			retryAfter = apiErr.RetryAfter
+		}
+		timer := time.NewTimer(c.Retry.backoff(attempt, retryAfter))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
 	}
-	return result, nil
 }
 
-// httpDelete creates a DELETE request
-func httpDelete(endpoint string, token string) (schema.DelResponse, error) {
-	var result schema.DelResponse
-	url := BaseAPI + endpoint
-	// Create a Bearer string by appending string access token
-	bearer := "Bearer " + token
-	// Create a new request using http
-	req, err := http.NewRequest("DELETE", url, nil)
+// send performs a single attempt of the request
+func (c *Client) send(ctx context.Context, method, endpoint string, body []byte, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+endpoint, reqBody)
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
 	// add authorization header to the req
-	req.Header.Add("Authorization", bearer)
-	// Send req using http Client
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("User-Agent", c.UserAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		fmt.Println("Error on response.\nERROR -", err)
+		return fmt.Errorf("%s %s: %w", method, endpoint, err)
 	}
 	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+
+	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Println("Error while reading the response bytes:", err)
+		return fmt.Errorf("%s %s: failed to read response body: %w", method, endpoint, err)
 	}
-	err = json.Unmarshal(body, &result)
-	if err != nil {
-		fmt.Println("Error while parsing the response bytes:", err)
+
+	apiErr := &APIError{
+		Method:     method,
+		Endpoint:   endpoint,
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		if resp.StatusCode >= http.StatusBadRequest {
+			return apiErr
+		}
+		return fmt.Errorf("%s %s: failed to parse response body: %w", method, endpoint, err)
 	}
-	if len(result.Errors) > 0 {
-		return schema.DelResponse{}, fmt.Errorf("%s", result.Errors[0].Message)
+	if resp.StatusCode >= http.StatusBadRequest || !env.Success || len(env.Errors) > 0 {
+		apiErr.Errors = env.Errors
+		return apiErr
 	}
-	return result, nil
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%s %s: failed to parse response body: %w", method, endpoint, err)
+	}
+	return nil
 }
@@ -0,0 +1,227 @@
+package cloudflare_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mrinjamul/flareship/internal/cloudflare"
+	"github.com/mrinjamul/flareship/internal/cloudflare/cloudflaretest"
+	"github.com/mrinjamul/flareship/pkg/schema"
+)
+
+const zoneID = "z1"
+
+// newServer starts a fake api with an empty example.com zone
+func newServer(t *testing.T) *cloudflaretest.Server {
+	t.Helper()
+	server := cloudflaretest.NewServer()
+	server.AddZone(zoneID, "example.com")
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// seedRecords adds n records of the type with distinct names
+func seedRecords(server *cloudflaretest.Server, recordType, content string, n int) {
+	var records []schema.Record
+	for i := 0; i < n; i++ {
+		records = append(records, schema.Record{Type: recordType, Name: fmt.Sprintf("%s%d", strings.ToLower(recordType), i), Content: content, TTL: schema.TTLAuto})
+	}
+	server.Seed(zoneID, records...)
+}
+
+// countRequests returns the number of requests with the method
+func countRequests(server *cloudflaretest.Server, method string) int {
+	var n int
+	for _, r := range server.Requests() {
+		if strings.HasPrefix(r, method+" ") {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRecordsPaginates(t *testing.T) {
+	server := newServer(t)
+	seedRecords(server, "A", "1.1.1.1", 250)
+	seedRecords(server, "TXT", "v=spf1 -all", 150)
+	seedRecords(server, "CNAME", "example.com", 5)
+	client := server.Client()
+
+	tests := []struct {
+		types []string
+		want  int
+	}{
+		{[]string{"A"}, 250},
+		{[]string{"TXT"}, 150},
+		{[]string{"A", "TXT"}, 400},
+		{nil, 405},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.types), func(t *testing.T) {
+			records, err := client.ReadAllRecords(context.Background(), zoneID, tt.types)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != tt.want {
+				t.Fatalf("got %d records, want %d", len(records), tt.want)
+			}
+			seen := map[string]bool{}
+			for _, r := range records {
+				if seen[r.ID] {
+					t.Fatalf("record %s returned twice", r.ID)
+				}
+				seen[r.ID] = true
+				if len(tt.types) > 0 && r.Type != tt.types[0] && r.Type != tt.types[len(tt.types)-1] {
+					t.Fatalf("unexpected %s record %s", r.Type, r.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordsError(t *testing.T) {
+	server := newServer(t)
+	seedRecords(server, "A", "1.1.1.1", 150)
+	server.Fail(cloudflaretest.Failure{Method: http.MethodGet, StatusCode: http.StatusForbidden, Code: cloudflaretest.CodeAuthentication, Message: "Authentication error"})
+
+	it := server.Client().Records(context.Background(), zoneID, []string{"A"})
+	if it.Next() {
+		t.Fatal("Next returned a record after an error")
+	}
+	var apiErr *cloudflare.APIError
+	if !errors.As(it.Err(), &apiErr) || !apiErr.HasCode(cloudflaretest.CodeAuthentication) {
+		t.Fatalf("got error %v, want the authentication error", it.Err())
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		times    int
+		requests int
+		fails    bool
+	}{
+		{"rate limited", http.StatusTooManyRequests, 1, 2, false},
+		{"server error", http.StatusServiceUnavailable, 2, 3, false},
+		{"gives up", http.StatusInternalServerError, 0, cloudflare.DefaultMaxRetries + 1, true},
+		{"client error", http.StatusBadRequest, 1, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newServer(t)
+			seedRecords(server, "A", "1.1.1.1", 1)
+			server.Fail(cloudflaretest.Failure{Method: http.MethodGet, StatusCode: tt.status, Code: cloudflaretest.CodeInternal, Message: "failure", Times: tt.times})
+
+			records, err := server.Client().ReadAllRecords(context.Background(), zoneID, []string{"A"})
+			if tt.fails {
+				var apiErr *cloudflare.APIError
+				if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+					t.Fatalf("got error %v, want status %d", err, tt.status)
+				}
+			} else if err != nil || len(records) != 1 {
+				t.Fatalf("got %d records and error %v, want 1 record", len(records), err)
+			}
+			if n := countRequests(server, http.MethodGet); n != tt.requests {
+				t.Fatalf("got %d requests, want %d", n, tt.requests)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	server := newServer(t)
+	server.Fail(cloudflaretest.Failure{Method: http.MethodGet, StatusCode: http.StatusTooManyRequests, Code: 971, Message: "Please wait", RetryAfter: time.Second, Times: 1})
+	client := server.Client()
+	client.Retry.WaitMax = 5 * time.Second
+
+	start := time.Now()
+	if _, err := client.ReadAllRecords(context.Background(), zoneID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("retried after %v, want the Retry-After of 1s", elapsed)
+	}
+}
+
+func TestNoRetryOnPost(t *testing.T) {
+	server := newServer(t)
+	server.Fail(cloudflaretest.Failure{Method: http.MethodPost, StatusCode: http.StatusServiceUnavailable, Code: cloudflaretest.CodeInternal, Message: "failure", Times: 1})
+
+	record := schema.Record{Type: "A", Name: "www.example.com", Content: "1.1.1.1", TTL: schema.TTLAuto}
+	if _, err := server.Client().CreateRecord(context.Background(), zoneID, record); err == nil {
+		t.Fatal("CreateRecord succeeded, want the server error")
+	}
+	if n := countRequests(server, http.MethodPost); n != 1 {
+		t.Fatalf("got %d POST requests, want 1", n)
+	}
+	if records := server.Records(zoneID); len(records) != 0 {
+		t.Fatalf("got %d records, want none", len(records))
+	}
+}
+
+func TestBatchRecords(t *testing.T) {
+	server := newServer(t)
+	seeded := server.Seed(zoneID,
+		schema.Record{Type: "A", Name: "old", Content: "1.1.1.1", TTL: schema.TTLAuto},
+		schema.Record{Type: "A", Name: "www", Content: "2.2.2.2", TTL: schema.TTLAuto},
+	)
+	client := server.Client()
+	updated := seeded[1]
+	updated.Content = "3.3.3.3"
+	batch := schema.BatchRequest{
+		Deletes: []schema.Record{{ID: seeded[0].ID}},
+		Puts:    []schema.Record{updated},
+		Posts:   []schema.Record{{Type: "A", Name: "new.example.com", Content: "4.4.4.4", TTL: schema.TTLAuto}},
+	}
+
+	t.Run("rollback", func(t *testing.T) {
+		failing := batch
+		// the TXT record lacks its content and fails after the other changes
+		failing.Posts = append(failing.Posts, schema.Record{Type: "TXT", Name: "example.com", TTL: schema.TTLAuto})
+		before := server.Records(zoneID)
+		if _, err := client.BatchRecords(context.Background(), zoneID, failing); err == nil {
+			t.Fatal("BatchRecords succeeded, want the invalid record error")
+		}
+		after := server.Records(zoneID)
+		if len(after) != len(before) {
+			t.Fatalf("got %d records after the failed batch, want %d", len(after), len(before))
+		}
+		for i := range before {
+			if after[i].ID != before[i].ID || after[i].Content != before[i].Content {
+				t.Fatalf("record %s changed to %s by the failed batch", before[i].Name, after[i].Content)
+			}
+		}
+	})
+
+	t.Run("applied", func(t *testing.T) {
+		result, err := client.BatchRecords(context.Background(), zoneID, batch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Deletes) != 1 || len(result.Puts) != 1 || len(result.Posts) != 1 {
+			t.Fatalf("got %d deletes, %d puts and %d posts, want one each", len(result.Deletes), len(result.Puts), len(result.Posts))
+		}
+		var contents []string
+		for _, r := range server.Records(zoneID) {
+			contents = append(contents, r.Content)
+		}
+		if got := strings.Join(contents, " "); got != "4.4.4.4 3.3.3.3" {
+			t.Fatalf("got records %s, want 4.4.4.4 3.3.3.3", got)
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		client := server.Client()
+		client.BatchLimit = 2
+		if _, err := client.BatchRecords(context.Background(), zoneID, batch); !errors.Is(err, cloudflare.ErrBatchTooLarge) {
+			t.Fatalf("got error %v, want ErrBatchTooLarge", err)
+		}
+	})
+}
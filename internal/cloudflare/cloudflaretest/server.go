@@ -0,0 +1,556 @@
+// Package cloudflaretest provides an in-memory implementation of the
+// cloudflare zones/dns_records api for tests and offline demos.
+package cloudflaretest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mrinjamul/flareship/internal/cloudflare"
+	"github.com/mrinjamul/flareship/internal/utils"
+	"github.com/mrinjamul/flareship/pkg/schema"
+)
+
+// PathPrefix is the path the api is served under, like the real api
+const PathPrefix = "/client/v4/"
+
+// Cloudflare error codes returned by the fake
+const (
+	CodeAuthentication  = 10000
+	CodeInvalidZone     = 7003
+	CodeInvalidRequest  = 9207
+	CodeRecordNotFound  = 81044
+	CodeIdenticalRecord = 81058
+	CodeInternal        = 10001
+)
+
+// Failure describes an error response that is returned instead of handling
+// the request. It is used to inject failures such as rate limiting.
+type Failure struct {
+	// Method matches the request method, empty matches any method
+	Method string
+	// Path matches requests whose path contains it, empty matches any path
+	Path string
+	// StatusCode is the HTTP status of the response
+	StatusCode int
+	// Code and Message are returned in the error envelope
+	Code    uint
+	Message string
+	// RetryAfter is sent as the Retry-After header when set
+	RetryAfter time.Duration
+	// Times is the number of requests to fail, 0 fails every matching request
+	Times int
+}
+
+// zone is a zone with its records
+type zone struct {
+	name    string
+	records []schema.Result
+}
+
+// Server is an in-memory fake of the cloudflare dns records api.
+// It implements http.Handler and can be started as a test server.
+type Server struct {
+	// Token, if set, is the only api token accepted by the server
+	Token string
+
+	mu       sync.Mutex
+	zones    map[string]*zone
+	failures []*Failure
+	requests []string
+	nextID   int
+	now      func() time.Time
+
+	httpServer *httptest.Server
+}
+
+// NewServer returns an empty server which is not yet listening
+func NewServer() *Server {
+	return &Server{
+		zones: map[string]*zone{},
+		now:   time.Now,
+	}
+}
+
+// Start starts listening on a local port and returns the base url of the api
+func (s *Server) Start() string {
+	s.httpServer = httptest.NewServer(s)
+	return s.URL()
+}
+
+// URL returns the base url of the api of a started server
+func (s *Server) URL() string {
+	if s.httpServer == nil {
+		return ""
+	}
+	return s.httpServer.URL + PathPrefix
+}
+
+// Close shuts down a started server
+func (s *Server) Close() {
+	if s.httpServer != nil {
+		s.httpServer.Close()
+	}
+}
+
+// Client returns a cloudflare client for the started server. Retries wait
+// only a millisecond and throttling is disabled to keep tests fast.
+func (s *Server) Client() *cloudflare.Client {
+	client := cloudflare.NewClient(s.Token)
+	client.BaseURL = s.URL()
+	client.HTTPClient = s.httpServer.Client()
+	client.Retry.WaitMin = time.Millisecond
+	client.Retry.WaitMax = time.Millisecond
+	client.Limiter = nil
+	return client
+}
+
+// AddZone adds an empty zone
+func (s *Server) AddZone(zoneID, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.zones[zoneID]; !ok {
+		s.zones[zoneID] = &zone{name: name}
+	}
+}
+
+// Seed adds records to the zone without going through the api and returns
+// them with their assigned IDs. Relative names are completed with the zone name.
+func (s *Server) Seed(zoneID string, records ...schema.Record) []schema.Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	z, ok := s.zones[zoneID]
+	if !ok {
+		panic(fmt.Sprintf("cloudflaretest: unknown zone %q", zoneID))
+	}
+	var seeded []schema.Record
+	for _, record := range records {
+		result := s.newResult(zoneID, z, record)
+		z.records = append(z.records, result)
+		seeded = append(seeded, recordOf(result))
+	}
+	return seeded
+}
+
+// Records returns the records of the zone sorted by name, type and content
+func (s *Server) Records(zoneID string) []schema.Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	z, ok := s.zones[zoneID]
+	if !ok {
+		return nil
+	}
+	var records []schema.Record
+	for _, result := range z.records {
+		records = append(records, recordOf(result))
+	}
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Content < b.Content
+	})
+	return records
+}
+
+// Fail injects a failure for matching requests
+func (s *Server) Fail(f Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, &f)
+}
+
+// ClearFailures removes all injected failures
+func (s *Server) ClearFailures() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = nil
+}
+
+// Requests returns the requests handled so far as "METHOD path" strings
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+
+	if f := s.matchFailure(r); f != nil {
+		if f.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int((f.RetryAfter+time.Second-1)/time.Second)))
+		}
+		writeError(w, f.StatusCode, f.Code, f.Message)
+		return
+	}
+	if s.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.Token {
+		writeError(w, http.StatusForbidden, CodeAuthentication, "Authentication error")
+		return
+	}
+
+	// zones/{zone_id}/dns_records[/{id}|/batch]
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, PathPrefix), "/"), "/")
+	if len(parts) < 3 || len(parts) > 4 || parts[0] != "zones" || parts[2] != "dns_records" {
+		writeError(w, http.StatusNotFound, CodeInvalidZone, "Could not route to "+r.URL.Path)
+		return
+	}
+	z, ok := s.zones[parts[1]]
+	if !ok {
+		writeError(w, http.StatusNotFound, CodeInvalidZone, "Could not route to "+r.URL.Path+", perhaps your object identifier is invalid?")
+		return
+	}
+	zoneID := parts[1]
+
+	switch {
+	case len(parts) == 3 && r.Method == http.MethodGet:
+		s.list(w, r, z)
+	case len(parts) == 3 && r.Method == http.MethodPost:
+		s.create(w, r, zoneID, z)
+	case len(parts) == 4 && parts[3] == "batch" && r.Method == http.MethodPost:
+		s.batch(w, r, zoneID, z)
+	case len(parts) == 4 && (r.Method == http.MethodPut || r.Method == http.MethodPatch):
+		s.update(w, r, z, parts[3])
+	case len(parts) == 4 && r.Method == http.MethodDelete:
+		s.delete(w, z, parts[3])
+	case len(parts) == 4 && r.Method == http.MethodGet:
+		s.get(w, z, parts[3])
+	default:
+		writeError(w, http.StatusMethodNotAllowed, CodeInvalidRequest, "Method not allowed")
+	}
+}
+
+// matchFailure returns the failure matching the request and consumes one use of it
+func (s *Server) matchFailure(r *http.Request) *Failure {
+	for i, f := range s.failures {
+		if f.Method != "" && f.Method != r.Method {
+			continue
+		}
+		if f.Path != "" && !strings.Contains(r.URL.Path, f.Path) {
+			continue
+		}
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+// list handles GET zones/{zone_id}/dns_records
+func (s *Server) list(w http.ResponseWriter, r *http.Request, z *zone) {
+	query := r.URL.Query()
+	var matched []schema.Result
+	for _, result := range z.records {
+		if t := query.Get("type"); t != "" && t != result.Type {
+			continue
+		}
+		if name := query.Get("name"); name != "" && name != result.Name {
+			continue
+		}
+		if content := query.Get("content"); content != "" && content != result.Content {
+			continue
+		}
+		matched = append(matched, result)
+	}
+
+	perPage, err := strconv.Atoi(query.Get("per_page"))
+	if err != nil || perPage <= 0 {
+		perPage = 100
+	}
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	totalPages := (len(matched) + perPage - 1) / perPage
+	start := (page - 1) * perPage
+	if start > len(matched) {
+		start = len(matched)
+	}
+	end := start + perPage
+	if end > len(matched) {
+		end = len(matched)
+	}
+
+	writeJSON(w, http.StatusOK, schema.CFResponse{
+		Success:  true,
+		Errors:   []schema.Errors{},
+		Messages: []string{},
+		ResultInfo: schema.ResultInfo{
+			Page:       uint(page),
+			PerPage:    uint(perPage),
+			Count:      uint(end - start),
+			TotalCount: uint(len(matched)),
+			TotalPages: uint(totalPages),
+		},
+		Result: append([]schema.Result{}, matched[start:end]...),
+	})
+}
+
+// get handles GET zones/{zone_id}/dns_records/{id}
+func (s *Server) get(w http.ResponseWriter, z *zone, id string) {
+	i := indexOf(z, id)
+	if i < 0 {
+		writeError(w, http.StatusNotFound, CodeRecordNotFound, "Record does not exist.")
+		return
+	}
+	writeResult(w, z.records[i])
+}
+
+// create handles POST zones/{zone_id}/dns_records
+func (s *Server) create(w http.ResponseWriter, r *http.Request, zoneID string, z *zone) {
+	var record schema.Record
+	if err := decode(r.Body, &record); err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	result, status, err := s.insert(zoneID, z, record)
+	if err != nil {
+		writeError(w, status, err.Code, err.Message)
+		return
+	}
+	writeResult(w, result)
+}
+
+// update handles PUT and PATCH zones/{zone_id}/dns_records/{id}
+func (s *Server) update(w http.ResponseWriter, r *http.Request, z *zone, id string) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	result, status, apiErr := s.replace(z, id, body, r.Method == http.MethodPatch)
+	if apiErr != nil {
+		writeError(w, status, apiErr.Code, apiErr.Message)
+		return
+	}
+	writeResult(w, result)
+}
+
+// delete handles DELETE zones/{zone_id}/dns_records/{id}
+func (s *Server) delete(w http.ResponseWriter, z *zone, id string) {
+	i := indexOf(z, id)
+	if i < 0 {
+		writeError(w, http.StatusNotFound, CodeRecordNotFound, "Record does not exist.")
+		return
+	}
+	z.records = append(z.records[:i], z.records[i+1:]...)
+	writeJSON(w, http.StatusOK, schema.DelResponse{Success: true, Errors: []schema.Errors{}, Result: schema.DelResult{ID: id}})
+}
+
+// batch handles POST zones/{zone_id}/dns_records/batch. The changes are
+// applied to a copy of the zone, which replaces the zone only if all succeed.
+func (s *Server) batch(w http.ResponseWriter, r *http.Request, zoneID string, z *zone) {
+	var req schema.BatchRequest
+	if err := decode(r.Body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	tx := &zone{name: z.name, records: append([]schema.Result{}, z.records...)}
+	nextID := s.nextID
+	var result schema.BatchResult
+	fail := func(status int, apiErr *schema.Errors) {
+		s.nextID = nextID
+		writeError(w, status, apiErr.Code, apiErr.Message)
+	}
+
+	for _, record := range req.Deletes {
+		i := indexOf(tx, record.ID)
+		if i < 0 {
+			fail(http.StatusNotFound, &schema.Errors{Code: CodeRecordNotFound, Message: "Record does not exist."})
+			return
+		}
+		result.Deletes = append(result.Deletes, tx.records[i])
+		tx.records = append(tx.records[:i], tx.records[i+1:]...)
+	}
+	for _, changes := range []struct {
+		records []schema.Record
+		patch   bool
+		out     *[]schema.Result
+	}{
+		{req.Patches, true, &result.Patches},
+		{req.Puts, false, &result.Puts},
+	} {
+		for _, record := range changes.records {
+			body, _ := json.Marshal(record)
+			updated, status, apiErr := s.replace(tx, record.ID, body, changes.patch)
+			if apiErr != nil {
+				fail(status, apiErr)
+				return
+			}
+			*changes.out = append(*changes.out, updated)
+		}
+	}
+	for _, record := range req.Posts {
+		created, status, apiErr := s.insert(zoneID, tx, record)
+		if apiErr != nil {
+			fail(status, apiErr)
+			return
+		}
+		result.Posts = append(result.Posts, created)
+	}
+
+	z.records = tx.records
+	writeJSON(w, http.StatusOK, schema.BatchResponse{Success: true, Errors: []schema.Errors{}, Result: result})
+}
+
+// insert validates and adds a new record to the zone
+func (s *Server) insert(zoneID string, z *zone, record schema.Record) (schema.Result, int, *schema.Errors) {
+	if apiErr := validate(record); apiErr != nil {
+		return schema.Result{}, http.StatusBadRequest, apiErr
+	}
+	result := s.newResult(zoneID, z, record)
+	for _, existing := range z.records {
+		if existing.Name == result.Name && existing.Type == result.Type && existing.Content == result.Content && reflect.DeepEqual(existing.Priority, result.Priority) {
+			return schema.Result{}, http.StatusBadRequest, &schema.Errors{Code: CodeIdenticalRecord, Message: "An identical record already exists."}
+		}
+	}
+	z.records = append(z.records, result)
+	return result, http.StatusOK, nil
+}
+
+// replace overwrites (PUT) or merges (PATCH) the record with the JSON body
+func (s *Server) replace(z *zone, id string, body []byte, patch bool) (schema.Result, int, *schema.Errors) {
+	i := indexOf(z, id)
+	if i < 0 {
+		return schema.Result{}, http.StatusNotFound, &schema.Errors{Code: CodeRecordNotFound, Message: "Record does not exist."}
+	}
+	existing := z.records[i]
+	var record schema.Record
+	if patch {
+		record = recordOf(existing)
+	}
+	if err := json.Unmarshal(body, &record); err != nil {
+		return schema.Result{}, http.StatusBadRequest, &schema.Errors{Code: CodeInvalidRequest, Message: err.Error()}
+	}
+	if apiErr := validate(record); apiErr != nil {
+		return schema.Result{}, http.StatusBadRequest, apiErr
+	}
+	result := fill(existing.ZoneID, z.name, record)
+	result.ID = existing.ID
+	result.CreatedOn = existing.CreatedOn
+	result.ModifiedOn = s.now().UTC().Format(time.RFC3339Nano)
+	z.records[i] = result
+	return result, http.StatusOK, nil
+}
+
+// newResult assigns an ID and timestamps to a new record
+func (s *Server) newResult(zoneID string, z *zone, record schema.Record) schema.Result {
+	s.nextID++
+	result := fill(zoneID, z.name, record)
+	result.ID = fmt.Sprintf("%032x", s.nextID)
+	result.CreatedOn = s.now().UTC().Format(time.RFC3339Nano)
+	result.ModifiedOn = result.CreatedOn
+	return result
+}
+
+// fill converts the record to an api result with the server side defaults
+func fill(zoneID, zoneName string, record schema.Record) schema.Result {
+	name := record.Name
+	switch {
+	case name == "@" || name == "":
+		name = zoneName
+	case name != zoneName && !strings.HasSuffix(name, "."+zoneName):
+		name = name + "." + zoneName
+	}
+	ttl := record.TTL
+	if ttl == 0 || record.Proxied {
+		ttl = 1
+	}
+	proxiable := schema.Proxiable(record.Type)
+	return schema.Result{
+		ZoneID:    zoneID,
+		ZoneName:  zoneName,
+		Name:      name,
+		Type:      record.Type,
+		Content:   record.Value(),
+		Proxiable: proxiable,
+		Proxied:   record.Proxied && proxiable,
+		TTL:       ttl,
+		Priority:  record.Priority,
+		Data:      record.Data,
+		Comment:   record.Comment,
+		Tags:      record.Tags,
+	}
+}
+
+// validate checks the fields required by the api
+func validate(record schema.Record) *schema.Errors {
+	if err := record.Validate(); err != nil {
+		return &schema.Errors{Code: CodeInvalidRequest, Message: err.Error()}
+	}
+	return nil
+}
+
+// recordOf converts an api result to a record
+func recordOf(result schema.Result) schema.Record {
+	return utils.ConcatOne(schema.Record{}, result)
+}
+
+// indexOf returns the index of the record with the ID or -1
+func indexOf(z *zone, id string) int {
+	for i, result := range z.records {
+		if result.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// decode decodes a JSON request body
+func decode(body io.Reader, v interface{}) error {
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		return fmt.Errorf("Malformed JSON in request body: %v", err)
+	}
+	return nil
+}
+
+// writeResult writes a single record response
+func writeResult(w http.ResponseWriter, result schema.Result) {
+	writeJSON(w, http.StatusOK, schema.PostResponse{Success: true, Errors: []schema.Errors{}, Messages: []string{}, Result: result})
+}
+
+// writeError writes an error envelope
+func writeError(w http.ResponseWriter, status int, code uint, message string) {
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	if code == 0 {
+		code = CodeInternal
+	}
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	writeJSON(w, status, map[string]interface{}{
+		"success":  false,
+		"errors":   []schema.Errors{{Code: code, Message: message}},
+		"messages": []string{},
+		"result":   nil,
+	})
+}
+
+// writeJSON writes v as JSON with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
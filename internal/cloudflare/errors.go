@@ -0,0 +1,63 @@
+package cloudflare
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mrinjamul/flareship/pkg/schema"
+)
+
+// ErrBatchTooLarge is returned when a batch exceeds the client's BatchLimit
+var ErrBatchTooLarge = errors.New("batch exceeds the batch size limit")
+
+// APIError is returned when the cloudflare api rejects a request
+type APIError struct {
+	// Method is the HTTP method of the failed request
+	Method string
+	// Endpoint is the API path of the failed request relative to the base url
+	Endpoint string
+	// StatusCode is the HTTP status code of the response
+	StatusCode int
+	// Errors are the errors reported in the response envelope
+	Errors []schema.Errors
+	// RetryAfter is the delay requested by the Retry-After header, if any
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	var msgs []string
+	for _, err := range e.Errors {
+		msg := fmt.Sprintf("%s (%d)", err.Message, err.Code)
+		for _, chain := range err.ErrorChain {
+			msg += fmt.Sprintf(": %s (%d)", chain.Message, chain.Code)
+		}
+		msgs = append(msgs, msg)
+	}
+	if len(msgs) == 0 {
+		msgs = append(msgs, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("cloudflare: %s %s: %d: %s", e.Method, e.Endpoint, e.StatusCode, strings.Join(msgs, "; "))
+}
+
+// Codes returns the cloudflare error codes of the response
+func (e *APIError) Codes() []uint {
+	var codes []uint
+	for _, err := range e.Errors {
+		codes = append(codes, err.Code)
+	}
+	return codes
+}
+
+// HasCode reports whether the response contains the given cloudflare error code
+func (e *APIError) HasCode(code uint) bool {
+	for _, c := range e.Codes() {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
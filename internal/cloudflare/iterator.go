@@ -0,0 +1,109 @@
+package cloudflare
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"github.com/mrinjamul/flareship/internal/utils"
+	"github.com/mrinjamul/flareship/pkg/schema"
+)
+
+// DefaultPerPage is the number of records requested per page
+const DefaultPerPage = 100
+
+// RecordIterator streams the records of a zone one page at a time.
+//
+//	it := client.Records(ctx, zoneID, []string{"A", "CNAME"})
+//	for it.Next() {
+//		record := it.Record()
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type RecordIterator struct {
+	ctx    context.Context
+	client *Client
+	zoneID string
+	// types filters the records client-side when more than one type is requested
+	types []string
+	query url.Values
+
+	page       int
+	totalPages int
+	done       bool
+	buf        []schema.Result
+	current    schema.Record
+	err        error
+}
+
+// Records returns an iterator over the records of the given types in the zone.
+// A single type is filtered by the API, several types are fetched with one
+// type-less query and filtered client-side. No types means all records.
+func (c *Client) Records(ctx context.Context, zoneID string, recordTypes []string) *RecordIterator {
+	it := &RecordIterator{
+		ctx:    ctx,
+		client: c,
+		zoneID: zoneID,
+		query:  url.Values{},
+	}
+	it.query.Set("per_page", strconv.Itoa(DefaultPerPage))
+	if len(recordTypes) == 1 {
+		it.query.Set("type", recordTypes[0])
+	} else {
+		it.types = recordTypes
+	}
+	return it
+}
+
+// Next advances to the next record. It returns false when there are no more
+// records or an error occurred, which is then reported by Err.
+func (it *RecordIterator) Next() bool {
+	for {
+		for len(it.buf) > 0 {
+			result := it.buf[0]
+			it.buf = it.buf[1:]
+			if len(it.types) > 0 && !utils.TypeContains(it.types, result.Type) {
+				continue
+			}
+			it.current = utils.ConcatOne(schema.Record{}, result)
+			return true
+		}
+		if it.done || it.err != nil {
+			return false
+		}
+		it.fetch()
+	}
+}
+
+// fetch reads the next page into the buffer
+func (it *RecordIterator) fetch() {
+	it.page++
+	it.query.Set("page", strconv.Itoa(it.page))
+	resp, err := it.client.ReadRecord(it.ctx, it.zoneID, it.query.Encode())
+	if err != nil {
+		it.err = err
+		return
+	}
+	it.buf = resp.Result
+	it.totalPages = int(resp.ResultInfo.TotalPages)
+	switch {
+	case len(resp.Result) == 0:
+		it.done = true
+	case it.totalPages > 0:
+		it.done = it.page >= it.totalPages
+	default:
+		// result_info is missing, a short page is the last one
+		it.done = len(resp.Result) < DefaultPerPage
+	}
+}
+
+// Record returns the current record
+func (it *RecordIterator) Record() schema.Record {
+	return it.current
+}
+
+// Err returns the first error encountered while iterating
+func (it *RecordIterator) Err() error {
+	return it.err
+}
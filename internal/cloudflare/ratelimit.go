@@ -0,0 +1,67 @@
+package cloudflare
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRateLimit is the number of requests allowed per DefaultRateLimitWindow.
+	// Cloudflare documents a global limit of 1200 requests per 5 minutes per token.
+	DefaultRateLimit = 1200
+	// DefaultRateLimitWindow is the window of DefaultRateLimit
+	DefaultRateLimitWindow = 5 * time.Minute
+)
+
+// RateLimiter throttles requests to at most limit requests in any sliding window.
+// A RateLimiter is safe for concurrent use and should be shared by all clients
+// using the same API token.
+type RateLimiter struct {
+	mu     sync.Mutex
+	window time.Duration
+	// sent holds the time of the last limit requests as a ring buffer
+	sent []time.Time
+	next int
+}
+
+// NewRateLimiter returns a limiter allowing limit requests per window.
+// It returns nil, which disables throttling, if limit or window is not positive.
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	if limit <= 0 || window <= 0 {
+		return nil
+	}
+	return &RateLimiter{
+		window: window,
+		sent:   make([]time.Time, limit),
+	}
+}
+
+// Wait blocks until a request is allowed or the context is done
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		// the oldest request in the buffer is the one that would be overwritten
+		oldest := l.sent[l.next]
+		if oldest.IsZero() || now.Sub(oldest) >= l.window {
+			l.sent[l.next] = now
+			l.next = (l.next + 1) % len(l.sent)
+			l.mu.Unlock()
+			return nil
+		}
+		wait := l.window - now.Sub(oldest)
+		l.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
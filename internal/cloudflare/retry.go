@@ -0,0 +1,92 @@
+package cloudflare
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultMaxRetries is the number of retries for a failed idempotent request
+	DefaultMaxRetries = 3
+	// DefaultRetryWaitMin is the base delay of the exponential backoff
+	DefaultRetryWaitMin = 500 * time.Millisecond
+	// DefaultRetryWaitMax caps the delay between two attempts
+	DefaultRetryWaitMax = 30 * time.Second
+)
+
+// RetryPolicy controls how failed idempotent requests (GET, PUT, DELETE) are
+// retried. Requests are retried on network errors, 429 and 5xx responses.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt, 0 disables retries
+	MaxRetries int
+	// WaitMin is the base delay of the exponential backoff
+	WaitMin time.Duration
+	// WaitMax caps the delay between two attempts
+	WaitMax time.Duration
+}
+
+// DefaultRetryPolicy returns the retry policy used by NewClient
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: DefaultMaxRetries,
+		WaitMin:    DefaultRetryWaitMin,
+		WaitMax:    DefaultRetryWaitMax,
+	}
+}
+
+// backoff returns the delay before the given retry attempt (starting at 0).
+// A Retry-After sent by the server takes precedence over the computed delay.
+func (p RetryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		if p.WaitMax > 0 && retryAfter > p.WaitMax {
+			return p.WaitMax
+		}
+		return retryAfter
+	}
+	wait := p.WaitMin << uint(attempt)
+	if wait <= 0 || (p.WaitMax > 0 && wait > p.WaitMax) {
+		wait = p.WaitMax
+	}
+	if wait <= 0 {
+		return 0
+	}
+	// full jitter spreads retries of concurrent clients
+	return time.Duration(rand.Int63n(int64(wait)) + 1)
+}
+
+// idempotent reports whether a request with the given method can be safely retried
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryable reports whether the error is worth another attempt
+func retryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// parseRetryAfter parses the Retry-After header in seconds or HTTP date form
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
@@ -0,0 +1,60 @@
+package cloudflare
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{MaxRetries: 3, WaitMin: 100 * time.Millisecond, WaitMax: 2 * time.Second}
+	tests := []struct {
+		attempt    int
+		retryAfter time.Duration
+		max        time.Duration
+	}{
+		{0, 0, 100 * time.Millisecond},
+		{2, 0, 400 * time.Millisecond},
+		{10, 0, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		if wait := p.backoff(tt.attempt, tt.retryAfter); wait <= 0 || wait > tt.max {
+			t.Errorf("backoff(%d) = %v, want at most %v", tt.attempt, wait, tt.max)
+		}
+	}
+	if wait := p.backoff(0, time.Second); wait != time.Second {
+		t.Errorf("backoff with Retry-After 1s = %v, want 1s", wait)
+	}
+	if wait := p.backoff(0, time.Minute); wait != p.WaitMax {
+		t.Errorf("backoff with Retry-After 1m = %v, want WaitMax", wait)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := parseRetryAfter("3"); d != 3*time.Second {
+		t.Errorf("parseRetryAfter(3) = %v, want 3s", d)
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if d := parseRetryAfter(date); d <= 0 || d > time.Minute {
+		t.Errorf("parseRetryAfter(%s) = %v, want up to a minute", date, d)
+	}
+	for _, value := range []string{"", "soon", "-1"} {
+		if d := parseRetryAfter(value); d != 0 {
+			t.Errorf("parseRetryAfter(%q) = %v, want 0", value, d)
+		}
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	for method, want := range map[string]bool{
+		http.MethodGet:    true,
+		http.MethodPut:    true,
+		http.MethodDelete: true,
+		http.MethodPost:   false,
+		http.MethodPatch:  false,
+	} {
+		if got := idempotent(method); got != want {
+			t.Errorf("idempotent(%s) = %v, want %v", method, got, want)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/mrinjamul/flareship/internal/fileformat"
 	"github.com/mrinjamul/flareship/internal/utils"
 	"github.com/mrinjamul/flareship/pkg/schema"
 )
@@ -67,7 +68,7 @@ func LoadConfig(path string) (*schema.AppConfig, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to get current working directory: %w", err)
 		}
-		path = filepath.Join(cwd, DefaultConfigFile)
+		path = findConfig(cwd)
 	}
 
 	_, present := os.LookupEnv("FLARESHIP_DOMAINS")
@@ -80,7 +81,7 @@ func LoadConfig(path string) (*schema.AppConfig, error) {
 	}
 
 	homeDir := utils.HomeDir()
-	configPath := filepath.Join(homeDir, ".config", "flareship.json")
+	configPath := findConfig(filepath.Join(homeDir, ".config"))
 
 	if info, err := os.Stat(configPath); err == nil && !info.IsDir() {
 		path = configPath // File exists and is not a directory
@@ -93,7 +94,7 @@ func LoadConfig(path string) (*schema.AppConfig, error) {
 		return &config, nil
 	}
 
-	if err := json.Unmarshal(bytes, &config); err != nil {
+	if err := fileformat.Unmarshal(fileformat.Detect(path), bytes, &config); err != nil {
 		return nil, fmt.Errorf("invalid config format: %w", err)
 	}
 
@@ -104,6 +105,19 @@ func LoadConfig(path string) (*schema.AppConfig, error) {
 	return &config, nil
 }
 
+// findConfig returns the config file in dir, flareship.json or its YAML or
+// TOML variant. It defaults to flareship.json if none exists.
+func findConfig(dir string) string {
+	name := strings.TrimSuffix(DefaultConfigFile, filepath.Ext(DefaultConfigFile))
+	for _, ext := range fileformat.Extensions {
+		path := filepath.Join(dir, name+ext)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return filepath.Join(dir, DefaultConfigFile)
+}
+
 // loadFromEnv supports environment variable configuration
 func loadFromEnv() (*schema.AppConfig, error) {
 	// Example:
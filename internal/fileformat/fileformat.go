@@ -0,0 +1,253 @@
+// Package fileformat reads and writes the JSON, YAML and TOML files of
+// flareship. The json tags of the schema are the single source of field
+// names, YAML and TOML documents are converted through JSON.
+package fileformat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the encoding of a file
+type Format string
+
+const (
+	// JSON is the default format
+	JSON Format = "json"
+	// YAML is used for .yaml and .yml files
+	YAML Format = "yaml"
+	// TOML is used for .toml files
+	TOML Format = "toml"
+)
+
+// Extensions lists the file extensions of the supported formats
+var Extensions = []string{".json", ".yaml", ".yml", ".toml"}
+
+// Detect returns the format of the file from its extension, JSON if unknown
+func Detect(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return YAML
+	case ".toml":
+		return TOML
+	}
+	return JSON
+}
+
+// Unmarshal decodes the data in the given format into v
+func Unmarshal(format Format, data []byte, v interface{}) error {
+	if format == JSON {
+		return json.Unmarshal(data, v)
+	}
+	generic, err := Decode(format, data)
+	if err != nil {
+		return err
+	}
+	return FromGeneric(generic, v)
+}
+
+// Decode decodes the data into generic maps, slices and scalars
+func Decode(format Format, data []byte) (interface{}, error) {
+	var generic interface{}
+	switch format {
+	case YAML:
+		if err := yaml.Unmarshal(data, &generic); err != nil {
+			return nil, err
+		}
+	case TOML:
+		table := map[string]interface{}{}
+		if err := toml.Unmarshal(data, &table); err != nil {
+			return nil, err
+		}
+		generic = table
+	default:
+		if err := json.Unmarshal(data, &generic); err != nil {
+			return nil, err
+		}
+	}
+	return generic, nil
+}
+
+// FromGeneric converts a generic value into v through JSON
+func FromGeneric(generic interface{}, v interface{}) error {
+	data, err := json.Marshal(generic)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// ToGeneric converts v into generic maps, slices and scalars through JSON.
+// Numbers are kept as json.Number so integers stay integers.
+func ToGeneric(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return generic, nil
+}
+
+// Marshal encodes v in the given format. JSON is indented with tabs. TOML
+// documents must be tables, so v must encode to a JSON object.
+func Marshal(format Format, v interface{}) ([]byte, error) {
+	switch format {
+	case YAML:
+		node, err := Node(v)
+		if err != nil {
+			return nil, err
+		}
+		return EncodeYAML(node)
+	case TOML:
+		generic, err := ToGeneric(v)
+		if err != nil {
+			return nil, err
+		}
+		table, ok := generic.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("toml documents must be tables")
+		}
+		var buf bytes.Buffer
+		enc := toml.NewEncoder(&buf)
+		enc.Indent = ""
+		if err := enc.Encode(pruneTables(table)); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	data, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Node converts v into a YAML node, keeping the field order of its JSON
+// encoding
+func Node(v interface{}) (*yaml.Node, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is YAML, decoding it into a node keeps the order of the fields
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	node := &doc
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) == 1 {
+		node = doc.Content[0]
+	}
+	blockStyle(node)
+	return node, nil
+}
+
+// EncodeYAML encodes the node with an indentation of two spaces
+func EncodeYAML(node *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// pruneTables drops the empty tables of the generic value, TOML would write
+// them as headers without keys
+func pruneTables(generic interface{}) interface{} {
+	switch v := generic.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if table, ok := value.(map[string]interface{}); ok && len(table) == 0 {
+				delete(v, key)
+				continue
+			}
+			v[key] = pruneTables(value)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = pruneTables(v[i])
+		}
+	}
+	return generic
+}
+
+// blockStyle clears the JSON flow and quoting styles of the node
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// Merge updates dst in place to hold the value of src. Comments, styles and
+// key order of dst are kept where the values did not change, new keys are
+// appended and empty mappings are not added.
+func Merge(dst, src *yaml.Node) {
+	if dst.Kind != src.Kind {
+		head, line, foot := dst.HeadComment, dst.LineComment, dst.FootComment
+		*dst = *src
+		dst.HeadComment, dst.LineComment, dst.FootComment = head, line, foot
+		return
+	}
+	switch dst.Kind {
+	case yaml.MappingNode:
+		var content []*yaml.Node
+		seen := map[string]bool{}
+		for i := 0; i+1 < len(dst.Content); i += 2 {
+			key, value := dst.Content[i], dst.Content[i+1]
+			if v := lookup(src, key.Value); v != nil {
+				Merge(value, v)
+				content = append(content, key, value)
+				seen[key.Value] = true
+			}
+		}
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			key, value := src.Content[i], src.Content[i+1]
+			if seen[key.Value] || value.Kind == yaml.MappingNode && len(value.Content) == 0 {
+				continue
+			}
+			content = append(content, key, value)
+		}
+		dst.Content = content
+	case yaml.SequenceNode:
+		n := len(dst.Content)
+		if len(src.Content) < n {
+			n = len(src.Content)
+		}
+		for i := 0; i < n; i++ {
+			Merge(dst.Content[i], src.Content[i])
+		}
+		dst.Content = append(dst.Content[:n], src.Content[n:]...)
+	case yaml.ScalarNode:
+		if dst.Tag != src.Tag {
+			dst.Style = src.Style
+		}
+		dst.Value, dst.Tag = src.Value, src.Tag
+	}
+}
+
+// lookup returns the value of the key in the mapping node, nil if missing
+func lookup(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
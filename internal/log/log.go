@@ -1,31 +1,295 @@
+// Package log prints leveled messages as text for people or as JSON lines for
+// log pipelines. Messages can carry structured fields, see With.
 package log
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
-var verbose bool
+// Level is the severity of a message
+type Level int
 
-// SetVerbose sets the verbosity level for logging.
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < DebugLevel || l > ErrorLevel {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel parses a level name: debug, info, warn or error
+func ParseLevel(name string) (Level, error) {
+	for i, n := range levelNames {
+		if strings.EqualFold(name, n) {
+			return Level(i), nil
+		}
+	}
+	if strings.EqualFold(name, "warning") {
+		return WarnLevel, nil
+	}
+	return InfoLevel, fmt.Errorf("invalid log level %q, use debug, info, warn or error", name)
+}
+
+// Format is the encoding of the messages
+type Format string
+
+const (
+	// TextFormat prints "[LEVEL] message" lines, fields are left out
+	TextFormat Format = "text"
+	// JSONFormat prints a JSON object per message with its time, level,
+	// message and fields
+	JSONFormat Format = "json"
+)
+
+// ParseFormat parses a format name: text or json
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(name)); f {
+	case TextFormat, JSONFormat:
+		return f, nil
+	}
+	return TextFormat, fmt.Errorf("invalid log format %q, use text or json", name)
+}
+
+// Fields are the structured fields of a message, e.g. the domain and record
+// it is about
+type Fields map[string]interface{}
+
+var (
+	mu     sync.Mutex
+	level  = InfoLevel
+	format = TextFormat
+	// output receives the messages, stdout unless changed with SetOutput
+	output io.Writer = os.Stdout
+	// quiet limits output to warnings and errors, the log file gets all
+	quiet bool
+	// file receives a copy of the messages, see SetFile
+	file   io.WriteCloser
+	errors int
+)
+
+// SetOutput sets the destination of the messages, e.g. os.Stderr when stdout
+// carries the output of a command
+func SetOutput(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	output = w
+}
+
+// SetLevel sets the lowest level printed
+func SetLevel(l Level) {
+	mu.Lock()
+	defer mu.Unlock()
+	level = l
+}
+
+// SetVerbose prints debug messages if v is set
 func SetVerbose(v bool) {
-	verbose = v
+	if v {
+		SetLevel(DebugLevel)
+	}
+}
+
+// SetQuiet only prints warnings and errors to the output if q is set. The log
+// file still gets every message.
+func SetQuiet(q bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	quiet = q
+}
+
+// SetFormat sets the encoding of the messages
+func SetFormat(f Format) {
+	mu.Lock()
+	defer mu.Unlock()
+	format = f
+}
+
+// SetFile appends a copy of the messages to the file at path, which is
+// created if needed
+func SetFile(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if file != nil {
+		file.Close()
+	}
+	file = f
+	return nil
+}
+
+// Close closes the log file
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if file == nil {
+		return nil
+	}
+	err := file.Close()
+	file = nil
+	return err
+}
+
+// Errors returns the number of errors logged so far, to set the exit status
+// once a command is done
+func Errors() int {
+	mu.Lock()
+	defer mu.Unlock()
+	return errors
+}
+
+// Entry is a message under construction with its fields
+type Entry struct {
+	fields Fields
+}
+
+// With returns an entry carrying the fields
+func With(fields Fields) *Entry {
+	return (&Entry{}).With(fields)
+}
+
+// With returns a copy of the entry with the fields added
+func (e *Entry) With(fields Fields) *Entry {
+	merged := make(Fields, len(e.fields)+len(fields))
+	for k, v := range e.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Entry{fields: merged}
+}
+
+// Debug prints debug messages if verbose mode is enabled.
+func (e *Entry) Debug(format string, a ...interface{}) {
+	e.log(DebugLevel, format, a...)
 }
 
 // Info prints informational messages.
-func Info(format string, a ...interface{}) {
-	fmt.Printf("[INFO] "+format+"\n", a...)
+func (e *Entry) Info(format string, a ...interface{}) {
+	e.log(InfoLevel, format, a...)
 }
 
-// Error prints error messages and exits.
-func Error(format string, a ...interface{}) {
-	fmt.Printf("[ERROR] "+format+"\n", a...)
+// Warn prints warning messages.
+func (e *Entry) Warn(format string, a ...interface{}) {
+	e.log(WarnLevel, format, a...)
+}
+
+// Error prints error messages, the command goes on.
+func (e *Entry) Error(format string, a ...interface{}) {
+	e.log(ErrorLevel, format, a...)
+}
+
+// Fatal prints error messages and exits.
+func (e *Entry) Fatal(format string, a ...interface{}) {
+	e.log(ErrorLevel, format, a...)
+	Close()
 	os.Exit(1)
 }
 
+// log writes the message to the output and the log file
+func (e *Entry) log(l Level, msg string, a ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+	if l == ErrorLevel {
+		errors++
+	}
+	if l < level {
+		return
+	}
+	// padding of aligned columns is dropped
+	msg = strings.TrimRight(fmt.Sprintf(msg, a...), " ")
+	line := encode(time.Now(), l, msg, e.fields)
+	if !quiet || l >= WarnLevel {
+		output.Write(line)
+	}
+	if file != nil {
+		file.Write(line)
+	}
+}
+
+// encode formats a message in the current format
+func encode(at time.Time, l Level, msg string, fields Fields) []byte {
+	if format == TextFormat {
+		return []byte("[" + strings.ToUpper(l.String()) + "] " + msg + "\n")
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(`{"time":`)
+	writeJSON(&b, at.UTC().Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	writeJSON(&b, l.String())
+	b.WriteString(`,"msg":`)
+	writeJSON(&b, msg)
+	for _, k := range keys {
+		b.WriteString(",")
+		writeJSON(&b, k)
+		b.WriteString(":")
+		writeJSON(&b, fields[k])
+	}
+	b.WriteString("}\n")
+	return []byte(b.String())
+}
+
+// writeJSON writes the value as JSON, or its text if it cannot be encoded
+func writeJSON(b *strings.Builder, v interface{}) {
+	if err, ok := v.(error); ok {
+		v = err.Error()
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	b.Write(data)
+}
+
+// std is the entry of the package functions, without fields
+var std = &Entry{}
+
 // Debug prints debug messages if verbose mode is enabled.
 func Debug(format string, a ...interface{}) {
-	if verbose {
-		fmt.Printf("[DEBUG] "+format+"\n", a...)
-	}
+	std.log(DebugLevel, format, a...)
+}
+
+// Info prints informational messages.
+func Info(format string, a ...interface{}) {
+	std.log(InfoLevel, format, a...)
+}
+
+// Warn prints warning messages.
+func Warn(format string, a ...interface{}) {
+	std.log(WarnLevel, format, a...)
+}
+
+// Error prints error messages, the command goes on.
+func Error(format string, a ...interface{}) {
+	std.log(ErrorLevel, format, a...)
+}
+
+// Fatal prints error messages and exits.
+func Fatal(format string, a ...interface{}) {
+	std.Fatal(format, a...)
 }
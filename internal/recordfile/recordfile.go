@@ -0,0 +1,188 @@
+// Package recordfile reads and rewrites records files in JSON, YAML or TOML.
+// YAML files keep their comments when they are written back.
+package recordfile
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/mrinjamul/flareship/internal/fileformat"
+	"github.com/mrinjamul/flareship/pkg/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// File is a parsed records file. JSON and YAML files hold a list of entries,
+// TOML files an array of tables named "records". A file can also hold a
+// single entry, e.g. one file per subdomain.
+type File struct {
+	Path    string
+	Format  fileformat.Format
+	Entries []schema.Records
+	// Single is set for files holding one entry instead of a list
+	Single bool
+
+	// doc and items are the YAML document and its entries, kept to write the
+	// comments back
+	doc   *yaml.Node
+	items []*yaml.Node
+}
+
+// tomlFile is the layout of TOML records files
+type tomlFile struct {
+	Records []schema.Records `json:"records"`
+}
+
+// Read parses the records file, its format is detected from the extension
+func Read(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(path, data)
+}
+
+// Parse parses the content of the records file at path
+func Parse(path string, data []byte) (*File, error) {
+	f := &File{Path: path, Format: fileformat.Detect(path)}
+	switch f.Format {
+	case fileformat.YAML:
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		if len(doc.Content) == 0 {
+			// empty file
+			return f, nil
+		}
+		root := doc.Content[0]
+		var generic interface{}
+		if err := root.Decode(&generic); err != nil {
+			return nil, err
+		}
+		switch root.Kind {
+		case yaml.SequenceNode:
+			f.items = root.Content
+		case yaml.MappingNode:
+			f.Single = true
+			f.items = []*yaml.Node{root}
+			generic = []interface{}{generic}
+		default:
+			return nil, fmt.Errorf("%s: records must be a list or a single entry", path)
+		}
+		if err := fileformat.FromGeneric(generic, &f.Entries); err != nil {
+			return nil, err
+		}
+		f.doc = &doc
+	case fileformat.TOML:
+		var t tomlFile
+		if err := fileformat.Unmarshal(f.Format, data, &t); err != nil {
+			return nil, err
+		}
+		f.Entries = t.Records
+		if len(t.Records) == 0 {
+			// a single entry has a [record] table instead of [[records]]
+			var entry schema.Records
+			if err := fileformat.Unmarshal(f.Format, data, &entry); err != nil {
+				return nil, err
+			}
+			if entry.Record.Type != "" {
+				f.Single = true
+				f.Entries = []schema.Records{entry}
+			}
+		}
+	default:
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+			var entry schema.Records
+			if err := fileformat.Unmarshal(f.Format, data, &entry); err != nil {
+				return nil, err
+			}
+			f.Single = true
+			f.Entries = []schema.Records{entry}
+			break
+		}
+		if err := fileformat.Unmarshal(f.Format, data, &f.Entries); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Remove removes the entry at index i, keeping the order of the others
+func (f *File) Remove(i int) {
+	f.Entries = append(f.Entries[:i], f.Entries[i+1:]...)
+	if f.items != nil {
+		f.items = append(f.items[:i], f.items[i+1:]...)
+	}
+}
+
+// Insert inserts the entry at index i
+func (f *File) Insert(i int, entry schema.Records) {
+	f.Entries = append(f.Entries[:i], append([]schema.Records{entry}, f.Entries[i:]...)...)
+	if f.items != nil {
+		f.items = append(f.items[:i], append([]*yaml.Node{nil}, f.items[i:]...)...)
+	}
+}
+
+// Marshal encodes the entries in the format of the file. The comments of a
+// YAML file stay with their entries.
+func (f *File) Marshal() ([]byte, error) {
+	if f.Single && len(f.Entries) == 1 {
+		return f.marshalSingle()
+	}
+	switch f.Format {
+	case fileformat.YAML:
+		if f.doc == nil || f.Single {
+			return fileformat.Marshal(f.Format, f.Entries)
+		}
+		var content []*yaml.Node
+		for i, entry := range f.Entries {
+			node, err := fileformat.Node(entry)
+			if err != nil {
+				return nil, err
+			}
+			// entries read from the file keep their comments
+			if i < len(f.items) && f.items[i] != nil {
+				fileformat.Merge(f.items[i], node)
+				node = f.items[i]
+			}
+			content = append(content, node)
+		}
+		f.doc.Content[0].Content = content
+		return fileformat.EncodeYAML(f.doc)
+	case fileformat.TOML:
+		return fileformat.Marshal(f.Format, tomlFile{Records: f.Entries})
+	}
+	return fileformat.Marshal(f.Format, f.Entries)
+}
+
+// marshalSingle encodes the only entry of a single entry file
+func (f *File) marshalSingle() ([]byte, error) {
+	entry := f.Entries[0]
+	switch f.Format {
+	case fileformat.YAML:
+		if f.doc == nil {
+			return fileformat.Marshal(f.Format, entry)
+		}
+		node, err := fileformat.Node(entry)
+		if err != nil {
+			return nil, err
+		}
+		fileformat.Merge(f.doc.Content[0], node)
+		return fileformat.EncodeYAML(f.doc)
+	}
+	return fileformat.Marshal(f.Format, entry)
+}
+
+// Write writes the entries back to the file. A single entry file whose
+// entry was removed is deleted.
+func (f *File) Write() error {
+	if f.Single && len(f.Entries) == 0 {
+		return os.Remove(f.Path)
+	}
+	data, err := f.Marshal()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.Path, data, 0644)
+}
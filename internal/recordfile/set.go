@@ -0,0 +1,129 @@
+package recordfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mrinjamul/flareship/internal/fileformat"
+	"github.com/mrinjamul/flareship/pkg/schema"
+)
+
+// Paths returns the records files of a record_file setting, which is a file,
+// a directory of records files or a glob like "domains/example.com/*.json".
+// Paths are sorted. A glob matching no file is an error.
+func Paths(pattern string) ([]string, error) {
+	info, err := os.Stat(pattern)
+	switch {
+	case err == nil && info.IsDir():
+		entries, err := os.ReadDir(pattern)
+		if err != nil {
+			return nil, err
+		}
+		var paths []string
+		for _, entry := range entries {
+			if !entry.IsDir() && supported(entry.Name()) {
+				paths = append(paths, filepath.Join(pattern, entry.Name()))
+			}
+		}
+		return paths, nil
+	case err == nil:
+		return []string{pattern}, nil
+	case !strings.ContainsAny(pattern, "*?["):
+		return nil, err
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid records pattern %s: %w", pattern, err)
+	}
+	var paths []string
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && !info.IsDir() {
+			paths = append(paths, match)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no records file matches %s", pattern)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// ReadAll reads the records files of a record_file setting, see Paths
+func ReadAll(pattern string) ([]*File, error) {
+	paths, err := Paths(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var files []*File
+	for _, path := range paths {
+		file, err := Read(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// Entries returns the entries of all files, in order
+func Entries(files []*File) []schema.Records {
+	var entries []schema.Records
+	for _, file := range files {
+		entries = append(entries, file.Entries...)
+	}
+	return entries
+}
+
+// Collision is a record name defined in more than one file
+type Collision struct {
+	Name  string
+	Files []string
+}
+
+func (c Collision) String() string {
+	return fmt.Sprintf("%s is defined in %s", c.Name, strings.Join(c.Files, ", "))
+}
+
+// Collisions returns the record names defined in more than one file. A file
+// may define several records of the same name, e.g. A and AAAA.
+func Collisions(files []*File) []Collision {
+	defined := map[string][]string{}
+	var names []string
+	for _, file := range files {
+		seen := map[string]bool{}
+		for _, entry := range file.Entries {
+			name := strings.ToLower(entry.Record.Name)
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			if _, ok := defined[name]; !ok {
+				names = append(names, name)
+			}
+			defined[name] = append(defined[name], file.Path)
+		}
+	}
+
+	var collisions []Collision
+	for _, name := range names {
+		if len(defined[name]) > 1 {
+			collisions = append(collisions, Collision{Name: name, Files: defined[name]})
+		}
+	}
+	return collisions
+}
+
+// supported reports whether the file has the extension of a records file
+func supported(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range fileformat.Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,256 @@
+// Package report holds the machine-readable results of the diff, sync and
+// list commands. The schema is versioned, fields are only added within a
+// version.
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mrinjamul/flareship/pkg/plan"
+	"github.com/mrinjamul/flareship/pkg/schema"
+)
+
+// Version is the version of the report schema
+const Version = 1
+
+// Status of a domain in a change report
+const (
+	// Planned changes were computed but not applied, by diff or a dry run
+	Planned = "planned"
+	// Applied changes were all applied
+	Applied = "applied"
+	// Failed domains could not be planned or applied, the counts are the
+	// changes applied before the failure
+	Failed = "failed"
+)
+
+// Record is a DNS record with fully qualified name
+type Record struct {
+	Domain string `json:"domain"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	// Value is the content, or the structured data in zone file notation
+	Value    string   `json:"value"`
+	Priority *uint16  `json:"priority,omitempty"`
+	Proxied  bool     `json:"proxied"`
+	TTL      uint     `json:"ttl"`
+	Comment  string   `json:"comment,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	// ID is the cloudflare ID of remote records
+	ID string `json:"id,omitempty"`
+}
+
+// NewRecord returns the report of a record of the domain
+func NewRecord(domain string, r schema.Record) Record {
+	return Record{
+		Domain:   domain,
+		Name:     r.Name,
+		Type:     r.Type,
+		Value:    r.Value(),
+		Priority: r.Priority,
+		Proxied:  r.Proxied,
+		TTL:      r.TTL,
+		Comment:  r.Comment,
+		Tags:     r.Tags,
+		ID:       r.ID,
+	}
+}
+
+// display returns the value with the priority, as shown by the commands
+func (r *Record) display() string {
+	if r == nil {
+		return ""
+	}
+	if r.Priority != nil {
+		return fmt.Sprintf("%d %s", *r.Priority, r.Value)
+	}
+	return r.Value
+}
+
+// Change is a change to a record. Old is null for creates, New for deletes.
+type Change struct {
+	Action string  `json:"action"`
+	Domain string  `json:"domain"`
+	Name   string  `json:"name"`
+	Type   string  `json:"type"`
+	Old    *Record `json:"old"`
+	New    *Record `json:"new"`
+	// Fields are the changed fields of updates
+	Fields []plan.FieldChange `json:"fields,omitempty"`
+}
+
+// NewChange returns the report of a change to a record of the domain
+func NewChange(domain string, c plan.Change) Change {
+	r := c.Record()
+	change := Change{Action: string(c.Action), Domain: domain, Name: r.Name, Type: r.Type}
+	if c.Old != nil {
+		old := NewRecord(domain, *c.Old)
+		change.Old = &old
+	}
+	if c.New != nil {
+		n := NewRecord(domain, *c.New)
+		change.New = &n
+	}
+	if c.Old != nil && c.New != nil {
+		change.Fields = plan.Diff(*c.Old, *c.New)
+	}
+	return change
+}
+
+// Domain is the outcome of a diff or sync for a domain
+type Domain struct {
+	Domain  string `json:"domain"`
+	Status  string `json:"status"`
+	Created int    `json:"created"`
+	Updated int    `json:"updated"`
+	Deleted int    `json:"deleted"`
+	// Unmanaged are the remote records flareship leaves alone
+	Unmanaged int `json:"unmanaged"`
+	// Conflicts are the local record sets left unchanged, see Conflict
+	Conflicts int `json:"conflicts"`
+	// Snapshot is the ID of the snapshot taken before the changes
+	Snapshot string `json:"snapshot,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Apply records the outcome of applying the changes of the domain
+func (d *Domain) Apply(result plan.Result, err error) {
+	d.Status = Applied
+	d.Created, d.Updated, d.Deleted = result.Created, result.Updated, result.Deleted
+	if err != nil {
+		d.Status = Failed
+		d.Error = err.Error()
+	}
+}
+
+// Conflict is a local record set left unchanged because remote records not
+// managed by flareship hold other values. It stays unresolved until the
+// records are adopted.
+type Conflict struct {
+	Domain string   `json:"domain"`
+	Name   string   `json:"name"`
+	Type   string   `json:"type"`
+	Local  []Record `json:"local"`
+	Remote []Record `json:"remote"`
+}
+
+// NewConflict returns the report of a conflict of the domain
+func NewConflict(domain string, c plan.Conflict) Conflict {
+	conflict := Conflict{Domain: domain, Name: c.Desired[0].Name, Type: c.Desired[0].Type}
+	for _, r := range c.Desired {
+		conflict.Local = append(conflict.Local, NewRecord(domain, r))
+	}
+	for _, r := range c.Remote {
+		conflict.Remote = append(conflict.Remote, NewRecord(domain, r))
+	}
+	return conflict
+}
+
+// Changes is the report of diff and sync
+type Changes struct {
+	Version   int        `json:"version"`
+	Domains   []Domain   `json:"domains"`
+	Changes   []Change   `json:"changes"`
+	Conflicts []Conflict `json:"conflicts"`
+}
+
+// NewChanges returns an empty change report
+func NewChanges() *Changes {
+	return &Changes{Version: Version, Domains: []Domain{}, Changes: []Change{}, Conflicts: []Conflict{}}
+}
+
+// Add adds the planned changes of a domain. It returns the domain entry to
+// record the outcome, valid until the next Add or Fail.
+func (r *Changes) Add(cs plan.ChangeSet) *Domain {
+	for _, c := range cs.Changes {
+		r.Changes = append(r.Changes, NewChange(cs.Domain, c))
+	}
+	for _, c := range cs.Conflicts {
+		r.Conflicts = append(r.Conflicts, NewConflict(cs.Domain, c))
+	}
+	r.Domains = append(r.Domains, Domain{
+		Domain:    cs.Domain,
+		Status:    Planned,
+		Created:   len(cs.Creates()),
+		Updated:   len(cs.Updates()),
+		Deleted:   len(cs.Deletes()),
+		Unmanaged: len(cs.Unmanaged),
+		Conflicts: len(cs.Conflicts),
+	})
+	return &r.Domains[len(r.Domains)-1]
+}
+
+// Fail records a domain which failed before its changes were planned
+func (r *Changes) Fail(domain string, err error) {
+	r.Domains = append(r.Domains, Domain{Domain: domain, Status: Failed, Error: err.Error()})
+}
+
+// Header returns the columns of the changes in a table
+func (r *Changes) Header() []string {
+	return []string{"action", "domain", "type", "name", "old", "new", "fields"}
+}
+
+// Rows returns the changes as table rows. The fields column lists the changed
+// fields of updates, e.g. "ttl:300→600;proxied:false→true". Conflicts follow
+// with the remote values as old and the local values as new.
+func (r *Changes) Rows() [][]string {
+	rows := [][]string{}
+	for _, c := range r.Changes {
+		var fields []string
+		for _, f := range c.Fields {
+			fields = append(fields, f.Field+":"+f.Old+"→"+f.New)
+		}
+		rows = append(rows, []string{c.Action, c.Domain, c.Type, c.Name, c.Old.display(), c.New.display(), strings.Join(fields, ";")})
+	}
+	for _, c := range r.Conflicts {
+		rows = append(rows, []string{"conflict", c.Domain, c.Type, c.Name, displayAll(c.Remote), displayAll(c.Local), ""})
+	}
+	return rows
+}
+
+// displayAll returns the values of the records as a list
+func displayAll(records []Record) string {
+	var values []string
+	for i := range records {
+		values = append(values, records[i].display())
+	}
+	return strings.Join(values, ", ")
+}
+
+// Records is the report of list
+type Records struct {
+	Version int      `json:"version"`
+	Records []Record `json:"records"`
+}
+
+// NewRecords returns an empty record report
+func NewRecords() *Records {
+	return &Records{Version: Version, Records: []Record{}}
+}
+
+// Add adds records of the domain
+func (r *Records) Add(domain string, records []schema.Record) {
+	for _, record := range records {
+		r.Records = append(r.Records, NewRecord(domain, record))
+	}
+}
+
+// Header returns the columns of the records in a table
+func (r *Records) Header() []string {
+	return []string{"domain", "type", "name", "value", "ttl", "proxied"}
+}
+
+// Rows returns the records as table rows
+func (r *Records) Rows() [][]string {
+	rows := [][]string{}
+	for i := range r.Records {
+		record := &r.Records[i]
+		ttl := fmt.Sprint(record.TTL)
+		if record.TTL == schema.TTLAuto {
+			ttl = "auto"
+		}
+		rows = append(rows, []string{record.Domain, record.Type, record.Name, record.display(), ttl, fmt.Sprint(record.Proxied)})
+	}
+	return rows
+}
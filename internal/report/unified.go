@@ -0,0 +1,177 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/mrinjamul/flareship/pkg/plan"
+)
+
+// ANSI colors of the unified diff, as used by git
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorCyan   = "\x1b[36m"
+)
+
+// maxStatWidth is the widest bar of WriteStat
+const maxStatWidth = 40
+
+// painter colors text if enabled
+type painter bool
+
+func (p painter) paint(color, text string) string {
+	if !p {
+		return text
+	}
+	return color + text + colorReset
+}
+
+// WriteUnified writes the changes like a unified diff from the remote
+// records (-) to the desired ones (+). Updates show every field, the changed
+// ones as -old and +new lines. Conflicts follow with the values of the
+// unmanaged remote records and the local ones, skipped records as context.
+func WriteUnified(w io.Writer, sets []plan.ChangeSet, color bool) error {
+	p := painter(color)
+	for _, cs := range sets {
+		if cs.Resolved() {
+			continue
+		}
+		fmt.Fprintln(w, p.paint(colorBold, "diff "+cs.Domain))
+		fmt.Fprintln(w, p.paint(colorBold, "--- remote"))
+		fmt.Fprintln(w, p.paint(colorBold, "+++ local"))
+		for _, c := range cs.Changes {
+			r := c.Record()
+			fmt.Fprintln(w, p.paint(colorCyan, fmt.Sprintf("@@ %s %s %s @@", c.Action, r.Type, r.Name)))
+			switch c.Action {
+			case plan.Create:
+				for _, f := range plan.Fields(*c.New) {
+					fmt.Fprintln(w, p.paint(colorGreen, "+"+f.Name+": "+f.Value))
+				}
+			case plan.Delete:
+				for _, f := range plan.Fields(*c.Old) {
+					fmt.Fprintln(w, p.paint(colorRed, "-"+f.Name+": "+f.Value))
+				}
+			case plan.Update:
+				changed := map[string]plan.FieldChange{}
+				for _, f := range plan.Diff(*c.Old, *c.New) {
+					changed[f.Field] = f
+				}
+				values := map[string]string{}
+				for _, f := range plan.Fields(*c.New) {
+					values[f.Name] = f.Value
+				}
+				for _, name := range plan.FieldNames {
+					f, ok := changed[name]
+					if !ok {
+						// unchanged fields are context
+						if value, ok := values[name]; ok {
+							fmt.Fprintln(w, " "+name+": "+value)
+						}
+						continue
+					}
+					if f.Old != "" {
+						fmt.Fprintln(w, p.paint(colorRed, "-"+name+": "+f.Old))
+					}
+					if f.New != "" {
+						fmt.Fprintln(w, p.paint(colorGreen, "+"+name+": "+f.New))
+					}
+				}
+			}
+		}
+		for _, c := range cs.Conflicts {
+			key := c.Key()
+			fmt.Fprintln(w, p.paint(colorYellow, fmt.Sprintf("@@ conflict %s %s @@", key.Type, c.Desired[0].Name)))
+			for _, r := range c.Remote {
+				fmt.Fprintln(w, p.paint(colorRed, "-value: "+r.Value()))
+			}
+			for _, r := range c.Desired {
+				fmt.Fprintln(w, p.paint(colorGreen, "+value: "+r.Value()))
+			}
+		}
+		for _, r := range cs.Skipped {
+			fmt.Fprintln(w, p.paint(colorYellow, fmt.Sprintf("@@ skip %s %s @@", r.Type, r.Name)))
+			fmt.Fprintln(w, " value: "+r.Value())
+		}
+	}
+	return nil
+}
+
+// statKey groups the changes of WriteStat
+type statKey struct {
+	domain, recordType string
+}
+
+// WriteStat writes the number of changes per domain and record type, with a
+// bar of + for creates, ~ for updates, - for deletes and ! for conflicting
+// record sets, and the totals
+func WriteStat(w io.Writer, sets []plan.ChangeSet, color bool) error {
+	p := painter(color)
+	counts := map[statKey]*plan.Result{}
+	conflicts := map[statKey]int{}
+	var keys []statKey
+	var total plan.Result
+	var domains, totalConflicts int
+	add := func(key statKey) {
+		if counts[key] == nil {
+			counts[key] = &plan.Result{}
+			keys = append(keys, key)
+		}
+	}
+	for _, cs := range sets {
+		if !cs.Resolved() {
+			domains++
+		}
+		for _, c := range cs.Changes {
+			key := statKey{domain: cs.Domain, recordType: c.Record().Type}
+			add(key)
+			counts[key].Add(c)
+			total.Add(c)
+		}
+		for _, c := range cs.Conflicts {
+			key := statKey{domain: cs.Domain, recordType: c.Key().Type}
+			add(key)
+			conflicts[key]++
+			totalConflicts++
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		if keys[i].domain != keys[j].domain {
+			return keys[i].domain < keys[j].domain
+		}
+		return keys[i].recordType < keys[j].recordType
+	})
+
+	width := 0
+	for _, key := range keys {
+		if n := len(key.domain) + 1 + len(key.recordType); n > width {
+			width = n
+		}
+	}
+	for _, key := range keys {
+		c := counts[key]
+		n := c.Created + c.Updated + c.Deleted + conflicts[key]
+		// scale the bar down if it would be too wide
+		scale := func(v int) int {
+			if n <= maxStatWidth || v == 0 {
+				return v
+			}
+			if s := v * maxStatWidth / n; s > 0 {
+				return s
+			}
+			return 1
+		}
+		bar := p.paint(colorGreen, strings.Repeat("+", scale(c.Created))) +
+			p.paint(colorYellow, strings.Repeat("~", scale(c.Updated))) +
+			p.paint(colorRed, strings.Repeat("-", scale(c.Deleted))) +
+			p.paint(colorYellow, strings.Repeat("!", scale(conflicts[key])))
+		fmt.Fprintf(w, " %-*s | %d %s\n", width, key.domain+" "+key.recordType, n, bar)
+	}
+	_, err := fmt.Fprintf(w, " %d domain(s) changed, %d create(s)(+), %d update(s)(~), %d delete(s)(-), %d conflict(s)(!)\n", domains, total.Created, total.Updated, total.Deleted, totalConflicts)
+	return err
+}
@@ -0,0 +1,72 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mrinjamul/flareship/internal/fileformat"
+)
+
+// Format is the output format of a report
+type Format string
+
+const (
+	// Text is the default, the results are only logged
+	Text  Format = "text"
+	JSON  Format = "json"
+	YAML  Format = "yaml"
+	Table Format = "table"
+	CSV   Format = "csv"
+)
+
+// Formats are the supported formats
+var Formats = []Format{Text, JSON, YAML, Table, CSV}
+
+// ParseFormat parses a format name
+func ParseFormat(name string) (Format, error) {
+	for _, f := range Formats {
+		if strings.EqualFold(name, string(f)) {
+			return f, nil
+		}
+	}
+	return Text, fmt.Errorf("invalid output format %q, use text, json, yaml, table or csv", name)
+}
+
+// Report is a report which can also be written as a table
+type Report interface {
+	Header() []string
+	Rows() [][]string
+}
+
+// Write writes the report in the format. Nothing is written for Text.
+func Write(w io.Writer, format Format, r Report) error {
+	switch format {
+	case JSON, YAML:
+		f := fileformat.JSON
+		if format == YAML {
+			f = fileformat.YAML
+		}
+		data, err := fileformat.Marshal(f, r)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case Table:
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(r.Header(), "\t")))
+		for _, row := range r.Rows() {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	case CSV:
+		cw := csv.NewWriter(w)
+		cw.Write(r.Header())
+		cw.WriteAll(r.Rows())
+		return cw.Error()
+	}
+	return nil
+}
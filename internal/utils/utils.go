@@ -1,7 +1,6 @@
 package utils
 
 import (
-	"encoding/json"
 	"fmt"
 	"math/rand"
 	"os"
@@ -9,6 +8,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/mrinjamul/flareship/internal/fileformat"
+	"github.com/mrinjamul/flareship/internal/recordfile"
 	"github.com/mrinjamul/flareship/pkg/schema"
 )
 
@@ -38,19 +39,22 @@ func GenTips() string {
 	return tips[r.Intn(len(tips))]
 }
 
-// GetRecords parse records from records file
+// GetRecords parse records from records file, in JSON, YAML or TOML. The
+// filename can also be a directory or a glob of records files, which are
+// merged. A name defined in several files is an error.
 func GetRecords(filename string) ([]schema.Records, error) {
-	var records []schema.Records
-	data, err := os.ReadFile(filename)
+	files, err := recordfile.ReadAll(filename)
 	if err != nil {
 		return []schema.Records{}, err
 	}
-	err = json.Unmarshal(data, &records)
-	if err != nil {
-
-		return []schema.Records{}, err
+	if collisions := recordfile.Collisions(files); len(collisions) > 0 {
+		var problems []string
+		for _, c := range collisions {
+			problems = append(problems, c.String())
+		}
+		return []schema.Records{}, fmt.Errorf("duplicate record names: %s", strings.Join(problems, "; "))
 	}
-	return records, nil
+	return recordfile.Entries(files), nil
 }
 
 // TypeContains checks if a given type is in the given types
@@ -66,73 +70,30 @@ func TypeContains(types []string, typeToCheck string) bool {
 // GetDNSRecords returns the DNS records with the given type
 func GetDNSRecords(filename string, enabledRecordType []string) ([]schema.Record, error) {
 	var records []schema.Record
-	entries, err := GetRecords(filename)
+	entries, err := GetDNSEntries(filename, enabledRecordType)
 	if err != nil {
 		return []schema.Record{}, err
 	}
 	for _, entry := range entries {
-		if TypeContains(enabledRecordType, entry.Record.Type) {
-			records = append(records, entry.Record)
-		}
+		records = append(records, entry.Record)
 	}
 	return records, nil
 }
 
-// FindRecordByName returns the record from name
-func FindRecordByName(records []schema.Record, name string) schema.Record {
-	for _, record := range records {
-		if record.Name == name {
-			return record
-		}
-	}
-	return schema.Record{}
-}
-
-// FindRecordID returns the record ID from name
-func FindRecordID(records []schema.Record, name string) string {
-	for _, r := range records {
-		if r.Name == name {
-			return r.ID
-		}
-	}
-	return ""
-}
-
-// RecordContain checks if a single record is in the records
-func RecordContain(records []schema.Record, record schema.Record) bool {
-	for _, r := range records {
-		if r.Name == record.Name {
-			return true
-		}
+// GetDNSEntries returns the entries of the records file with the given type,
+// including their metadata
+func GetDNSEntries(filename string, enabledRecordType []string) ([]schema.Records, error) {
+	var result []schema.Records
+	entries, err := GetRecords(filename)
+	if err != nil {
+		return []schema.Records{}, err
 	}
-	return false
-}
-
-// RecordContains checks if the sub-record is in the records
-func RecordContains(records []schema.Record, subrecords []schema.Record) bool {
-	for _, r := range subrecords {
-		if !RecordContain(records, r) {
-			return false
+	for _, entry := range entries {
+		if TypeContains(enabledRecordType, entry.Record.Type) {
+			result = append(result, entry)
 		}
 	}
-	return true
-}
-
-// Concat converts results to records
-func Concat(records []schema.Record, result []schema.Result) []schema.Record {
-	for _, r := range result {
-		// record := make(schema.Record, 0)
-		var record schema.Record
-		record.ID = r.ID
-		record.Type = r.Type
-		record.Name = r.Name
-		record.Content = r.Content
-		record.Proxiable = r.Proxiable
-		record.Proxied = r.Proxied
-		record.TTL = r.TTL
-		records = append(records, record)
-	}
-	return records
+	return result, nil
 }
 
 // ConcatOne concatenates from the result to record
@@ -144,23 +105,15 @@ func ConcatOne(record schema.Record, result schema.Result) schema.Record {
 	record.Proxiable = result.Proxiable
 	record.Proxied = result.Proxied
 	record.TTL = result.TTL
+	record.Priority = result.Priority
+	record.Data = result.Data
+	record.Comment = result.Comment
+	record.Tags = result.Tags
+	record.CreatedOn = result.CreatedOn
+	record.ModifiedOn = result.ModifiedOn
 	return record
 }
 
-// NewDate returns today as string
-func NewDate() string {
-	t := time.Now()
-	return t.Format("2006-01-02")
-}
-
-func RandomNumber() string {
-	// seed time
-	seed := time.Now().UnixNano()
-	r := rand.New(rand.NewSource(seed))
-	// generate random number
-	return fmt.Sprintf("%d", r.Intn(999))
-}
-
 // RemoveRestrictedSubdomains removes restricted subdomains from the list in restricted.json
 func RemoveRestrictedSubdomains(filename string, localRecords []schema.Record) (localNonRestrictedRecords []schema.Record, localRestrictedRecords []schema.Record) {
 	restrictedRecords := ReadRestrictedRecords(filename)
@@ -195,13 +148,22 @@ func ReadRestrictedRecords(filename string) []string {
 	if err != nil {
 		fmt.Println(err)
 	}
-	err = json.Unmarshal(file, &restrictedRecords)
+	err = fileformat.Unmarshal(fileformat.Detect(filename), file, &restrictedRecords)
 	if err != nil {
 		fmt.Println(err)
 	}
 	return restrictedRecords.RestrictedSubdomain
 }
 
+// IsTerminal reports whether the file is an interactive terminal
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
 // ConfirmPrompt will prompt to user for yes or no
 func ConfirmPrompt(message string) bool {
 	var response string
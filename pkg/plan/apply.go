@@ -0,0 +1,100 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mrinjamul/flareship/pkg/schema"
+)
+
+// Client is the part of the cloudflare api client used by Apply
+type Client interface {
+	CreateRecord(ctx context.Context, zoneID string, record schema.Record) (schema.Record, error)
+	UpdateRecord(ctx context.Context, zoneID, recordID string, record schema.Record) (schema.Record, error)
+	DeleteRecord(ctx context.Context, zoneID, recordID string) error
+}
+
+// BatchClient is a client which can apply several changes atomically
+type BatchClient interface {
+	BatchRecords(ctx context.Context, zoneID string, batch schema.BatchRequest) (schema.BatchResult, error)
+}
+
+// Result summarizes the changes applied to a zone
+type Result struct {
+	Created int
+	Updated int
+	Deleted int
+}
+
+// Add counts a change
+func (r *Result) Add(c Change) {
+	switch c.Action {
+	case Create:
+		r.Created++
+	case Update:
+		r.Updated++
+	case Delete:
+		r.Deleted++
+	}
+}
+
+// Apply applies the changes one record at a time: creates, then updates, then
+// deletes. It stops at the first failure, the result holds the changes applied
+// before it.
+func Apply(ctx context.Context, client Client, cs ChangeSet) (Result, error) {
+	var result Result
+	for _, group := range [][]Change{cs.Creates(), cs.Updates(), cs.Deletes()} {
+		for _, c := range group {
+			var err error
+			switch c.Action {
+			case Create:
+				_, err = client.CreateRecord(ctx, cs.ZoneID, *c.New)
+			case Update:
+				_, err = client.UpdateRecord(ctx, cs.ZoneID, c.Old.ID, *c.New)
+			case Delete:
+				err = client.DeleteRecord(ctx, cs.ZoneID, c.Old.ID)
+			}
+			if err != nil {
+				record := c.Record()
+				return result, fmt.Errorf("failed to %s %s:%s: %w", c.Action, record.Type, record.Name, err)
+			}
+			result.Add(c)
+		}
+	}
+	return result, nil
+}
+
+// ApplyAtomic applies all changes in a single batch request. Either every
+// change is applied or none is.
+func ApplyAtomic(ctx context.Context, client BatchClient, cs ChangeSet) (Result, error) {
+	var result Result
+	batch := Batch(cs)
+	if batch.Len() == 0 {
+		return result, nil
+	}
+	if _, err := client.BatchRecords(ctx, cs.ZoneID, batch); err != nil {
+		return result, err
+	}
+	for _, c := range cs.Changes {
+		result.Add(c)
+	}
+	return result, nil
+}
+
+// Batch converts the change set to a batch request
+func Batch(cs ChangeSet) schema.BatchRequest {
+	var batch schema.BatchRequest
+	for _, c := range cs.Changes {
+		switch c.Action {
+		case Create:
+			batch.Posts = append(batch.Posts, *c.New)
+		case Update:
+			record := *c.New
+			record.ID = c.Old.ID
+			batch.Puts = append(batch.Puts, record)
+		case Delete:
+			batch.Deletes = append(batch.Deletes, schema.Record{ID: c.Old.ID})
+		}
+	}
+	return batch
+}
@@ -0,0 +1,72 @@
+package plan
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mrinjamul/flareship/pkg/schema"
+)
+
+// Field is a field of a record as text
+type Field struct {
+	Name  string `json:"field"`
+	Value string `json:"value"`
+}
+
+// FieldChange is a field which differs between two versions of a record
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Fields returns the fields of the record compared by Diff: content,
+// priority, proxied, ttl, comment and tags. Priority, comment and tags are
+// left out when they are not set.
+func Fields(r schema.Record) []Field {
+	fields := []Field{{Name: "content", Value: r.Value()}}
+	if r.Priority != nil {
+		fields = append(fields, Field{Name: "priority", Value: fmt.Sprint(*r.Priority)})
+	}
+	fields = append(fields, Field{Name: "proxied", Value: fmt.Sprint(r.Proxied)})
+	ttl := fmt.Sprint(r.TTL)
+	if r.TTL == schema.TTLAuto {
+		ttl = "auto"
+	}
+	fields = append(fields, Field{Name: "ttl", Value: ttl})
+	if r.Comment != "" {
+		fields = append(fields, Field{Name: "comment", Value: r.Comment})
+	}
+	if len(r.Tags) > 0 {
+		tags := append([]string(nil), r.Tags...)
+		sort.Strings(tags)
+		fields = append(fields, Field{Name: "tags", Value: strings.Join(tags, ", ")})
+	}
+	return fields
+}
+
+// Diff returns the fields which differ between the old and new version of a
+// record, in the order of Fields. A field missing on one side is empty.
+func Diff(old, updated schema.Record) []FieldChange {
+	oldValues, newValues := fieldValues(old), fieldValues(updated)
+	var diff []FieldChange
+	for _, name := range FieldNames {
+		if oldValues[name] != newValues[name] {
+			diff = append(diff, FieldChange{Field: name, Old: oldValues[name], New: newValues[name]})
+		}
+	}
+	return diff
+}
+
+// FieldNames are the names of the fields returned by Fields, in order
+var FieldNames = []string{"content", "priority", "proxied", "ttl", "comment", "tags"}
+
+// fieldValues returns the fields of the record by name
+func fieldValues(r schema.Record) map[string]string {
+	values := map[string]string{}
+	for _, f := range Fields(r) {
+		values[f.Name] = f.Value
+	}
+	return values
+}
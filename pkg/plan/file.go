@@ -0,0 +1,128 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/mrinjamul/flareship/pkg/schema"
+)
+
+// FileVersion is the version of the saved plan format
+const FileVersion = 1
+
+// File is a saved plan which can be reviewed and applied later
+type File struct {
+	Version          int       `json:"version"`
+	CreatedAt        time.Time `json:"created_at"`
+	FlareshipVersion string    `json:"flareship_version,omitempty"`
+	Zones            []Zone    `json:"zones"`
+}
+
+// Zone is the saved plan of a single zone
+type Zone struct {
+	ChangeSet
+	// Types are the record types the plan was computed for
+	Types []string `json:"types"`
+	// Basis is the remote state the plan was computed from
+	Basis []BasisRecord `json:"basis"`
+}
+
+// BasisRecord identifies the version of a remote record
+type BasisRecord struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	Name       string `json:"name"`
+	ModifiedOn string `json:"modified_on"`
+}
+
+// NewZone returns the saved plan of the change set computed from the remote records
+func NewZone(cs ChangeSet, types []string, remote []schema.Record) Zone {
+	zone := Zone{ChangeSet: cs, Types: types}
+	for _, r := range remote {
+		zone.Basis = append(zone.Basis, BasisRecord{ID: r.ID, Type: r.Type, Name: r.Name, ModifiedOn: r.ModifiedOn})
+	}
+	sort.Slice(zone.Basis, func(i, j int) bool { return zone.Basis[i].ID < zone.Basis[j].ID })
+	return zone
+}
+
+// Verify returns an error if the remote records differ from the state the
+// plan was computed from, i.e. a record was added, removed or modified.
+func (z Zone) Verify(remote []schema.Record) error {
+	basis := map[string]BasisRecord{}
+	for _, b := range z.Basis {
+		basis[b.ID] = b
+	}
+
+	var drift []string
+	for _, r := range remote {
+		if !z.hasType(r.Type) {
+			continue
+		}
+		b, ok := basis[r.ID]
+		switch {
+		case !ok:
+			drift = append(drift, fmt.Sprintf("%s %s was added", r.Type, r.Name))
+		case b.ModifiedOn != r.ModifiedOn:
+			drift = append(drift, fmt.Sprintf("%s %s was modified on %s", r.Type, r.Name, r.ModifiedOn))
+		}
+		delete(basis, r.ID)
+	}
+	for _, b := range basis {
+		drift = append(drift, fmt.Sprintf("%s %s was deleted", b.Type, b.Name))
+	}
+	if len(drift) > 0 {
+		sort.Strings(drift)
+		return &DriftError{Domain: z.Domain, Changes: drift}
+	}
+	return nil
+}
+
+// hasType reports whether the plan covers the record type
+func (z Zone) hasType(recordType string) bool {
+	for _, t := range z.Types {
+		if t == recordType {
+			return true
+		}
+	}
+	return false
+}
+
+// DriftError is returned by Verify if the remote zone changed since the plan
+type DriftError struct {
+	Domain  string
+	Changes []string
+}
+
+// Error implements the error interface
+func (e *DriftError) Error() string {
+	return fmt.Sprintf("remote zone %s changed since the plan was made (%d change(s))", e.Domain, len(e.Changes))
+}
+
+// WriteFile writes the saved plan as indented JSON
+func WriteFile(path string, f File) error {
+	f.Version = FileVersion
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// ReadFile reads a saved plan
+func ReadFile(path string) (File, error) {
+	var f File
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return File{}, err
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return File{}, fmt.Errorf("invalid plan file: %w", err)
+	}
+	if f.Version != FileVersion {
+		return File{}, fmt.Errorf("unsupported plan file version %d", f.Version)
+	}
+	return f, nil
+}
@@ -0,0 +1,395 @@
+// Package plan computes and applies the changes needed to bring the DNS
+// records of a zone to a desired state.
+package plan
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/mrinjamul/flareship/pkg/schema"
+)
+
+// Action is the kind of a change
+type Action string
+
+const (
+	// Create adds a new record
+	Create Action = "create"
+	// Update replaces an existing record
+	Update Action = "update"
+	// Delete removes an existing record
+	Delete Action = "delete"
+)
+
+// Change is a single change to a record. Old is the remote record for
+// updates and deletes, New is the desired record for creates and updates.
+type Change struct {
+	Action Action         `json:"action"`
+	Old    *schema.Record `json:"old,omitempty"`
+	New    *schema.Record `json:"new,omitempty"`
+}
+
+// Record returns the record the change is about, the new one if present
+func (c Change) Record() schema.Record {
+	if c.New != nil {
+		return *c.New
+	}
+	if c.Old != nil {
+		return *c.Old
+	}
+	return schema.Record{}
+}
+
+// ChangeSet holds the changes of a zone, ordered by record set
+type ChangeSet struct {
+	Domain  string   `json:"domain,omitempty"`
+	ZoneID  string   `json:"zone_id,omitempty"`
+	Changes []Change `json:"changes"`
+	// Unmanaged are remote records not owned by flareship which are left
+	// alone. It is only set when planning with ownership.
+	Unmanaged []schema.Record `json:"unmanaged,omitempty"`
+	// Skipped are desired records which already exist remotely but are not
+	// owned by flareship. They can be claimed with Planner.Adopt.
+	Skipped []schema.Record `json:"skipped,omitempty"`
+	// Conflicts are desired record sets left unchanged because remote
+	// records not owned by flareship hold other values. They are resolved by
+	// claiming the records with Planner.Adopt.
+	Conflicts []Conflict `json:"conflicts,omitempty"`
+}
+
+// Conflict is a desired record set and the remote records not owned by
+// flareship which keep it from being planned
+type Conflict struct {
+	Desired []schema.Record `json:"desired"`
+	Remote  []schema.Record `json:"remote"`
+}
+
+// Key returns the record set key of the conflict
+func (c Conflict) Key() Key {
+	return KeyOf(c.Desired[0])
+}
+
+// Creates returns the create changes
+func (cs ChangeSet) Creates() []Change {
+	return cs.filter(Create)
+}
+
+// Updates returns the update changes
+func (cs ChangeSet) Updates() []Change {
+	return cs.filter(Update)
+}
+
+// Deletes returns the delete changes
+func (cs ChangeSet) Deletes() []Change {
+	return cs.filter(Delete)
+}
+
+// Empty reports whether there is nothing to change
+func (cs ChangeSet) Empty() bool {
+	return len(cs.Changes) == 0
+}
+
+// Resolved reports whether the remote records match the desired ones: there
+// is nothing to change, skip or resolve
+func (cs ChangeSet) Resolved() bool {
+	return cs.Empty() && len(cs.Skipped) == 0 && len(cs.Conflicts) == 0
+}
+
+// filter returns the changes with the given action
+func (cs ChangeSet) filter(action Action) []Change {
+	var changes []Change
+	for _, c := range cs.Changes {
+		if c.Action == action {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// Key identifies a record set, all records with the same name and type
+type Key struct {
+	Name string
+	Type string
+}
+
+// KeyOf returns the record set key of the record. Names are compared case
+// insensitive like DNS does.
+func KeyOf(record schema.Record) Key {
+	return Key{Name: strings.ToLower(record.Name), Type: strings.ToUpper(record.Type)}
+}
+
+// Group groups the records by record set
+func Group(records []schema.Record) map[Key][]schema.Record {
+	groups := map[Key][]schema.Record{}
+	for _, record := range records {
+		key := KeyOf(record)
+		groups[key] = append(groups[key], record)
+	}
+	return groups
+}
+
+// OwnerMarker marks the records owned by flareship in their comment
+const OwnerMarker = "managed-by:flareship"
+
+// Owned reports whether the record is owned by flareship
+func Owned(record schema.Record) bool {
+	return strings.Contains(record.Comment, OwnerMarker)
+}
+
+// Claim returns the record with the owner marker added to its comment
+func Claim(record schema.Record) schema.Record {
+	if !Owned(record) {
+		record.Comment = strings.TrimSpace(OwnerMarker + " " + record.Comment)
+	}
+	return record
+}
+
+// SameValue reports whether the records hold the same value, which is the
+// structured data for types having it and the content otherwise
+func SameValue(a, b schema.Record) bool {
+	if a.Data != nil || b.Data != nil {
+		return reflect.DeepEqual(a.Data, b.Data)
+	}
+	return a.Content == b.Content
+}
+
+// Equal reports whether the records have the same value and settings
+func Equal(a, b schema.Record) bool {
+	return SameValue(a, b) && a.Proxied == b.Proxied && a.TTL == b.TTL && reflect.DeepEqual(a.Priority, b.Priority)
+}
+
+// Planner computes change sets. The zero value plans like Plan.
+type Planner struct {
+	// Ownership restricts updates and deletes to records owned by flareship,
+	// see Owned. Desired records are claimed when they are written.
+	Ownership bool
+	// Adopt claims remote records which are not owned yet in the record sets
+	// of desired records, they are updated to the desired values. It
+	// requires Ownership.
+	Adopt bool
+	// Exact also compares comments and tags without Ownership, e.g. to
+	// restore a backup
+	Exact bool
+}
+
+// Plan computes the minimal changes to turn the actual records into the
+// desired records. Records are matched per record set (name and type), so
+// multi-valued sets like round-robin A or several TXT records are compared as
+// sets. Desired records of updates carry the ID of the record they replace.
+func Plan(desired, actual []schema.Record) ChangeSet {
+	return Planner{}.Plan(desired, actual)
+}
+
+// Plan computes the changes like the package level Plan, honoring ownership
+func (p Planner) Plan(desired, actual []schema.Record) ChangeSet {
+	var cs ChangeSet
+	if p.Ownership {
+		desired, actual = p.partition(&cs, desired, actual)
+	}
+
+	desiredSets := Group(desired)
+	actualSets := Group(actual)
+
+	var keys []Key
+	for key := range desiredSets {
+		keys = append(keys, key)
+	}
+	for key := range actualSets {
+		if _, ok := desiredSets[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sortKeys(keys)
+
+	for _, key := range keys {
+		cs.Changes = append(cs.Changes, p.planSet(desiredSets[key], actualSets[key])...)
+	}
+	return cs
+}
+
+// partition claims the desired records and keeps only the actual records
+// owned by flareship, or adopted. Unowned records are reported in the change
+// set. Desired records with the value of an unowned record are skipped, and an
+// unowned record with another value in a desired record set makes the whole
+// set a conflict, which is left unchanged instead of creating records next to
+// it.
+func (p Planner) partition(cs *ChangeSet, desired, actual []schema.Record) ([]schema.Record, []schema.Record) {
+	desiredSets := Group(desired)
+	conflicts := map[Key][]schema.Record{}
+	var owned []schema.Record
+	for _, a := range actual {
+		if Owned(a) {
+			owned = append(owned, a)
+			continue
+		}
+		set, wanted := desiredSets[KeyOf(a)]
+		switch {
+		case wanted && p.Adopt:
+			// adopted records are claimed by name and type, planSet
+			// updates them to the desired values
+			owned = append(owned, a)
+		case wanted && indexOf(set, func(d schema.Record) bool { return SameValue(d, a) }) >= 0:
+			cs.Skipped = append(cs.Skipped, a)
+			cs.Unmanaged = append(cs.Unmanaged, a)
+		case wanted:
+			conflicts[KeyOf(a)] = append(conflicts[KeyOf(a)], a)
+			cs.Unmanaged = append(cs.Unmanaged, a)
+		default:
+			cs.Unmanaged = append(cs.Unmanaged, a)
+		}
+	}
+
+	var keys []Key
+	for key := range conflicts {
+		keys = append(keys, key)
+	}
+	sortKeys(keys)
+	for _, key := range keys {
+		// unowned records with a desired value are part of the conflict
+		remote := conflicts[key]
+		for _, s := range cs.Skipped {
+			if KeyOf(s) == key {
+				remote = append(remote, s)
+			}
+		}
+		cs.Conflicts = append(cs.Conflicts, Conflict{Desired: desiredSets[key], Remote: remote})
+	}
+	var skipped []schema.Record
+	for _, s := range cs.Skipped {
+		if _, ok := conflicts[KeyOf(s)]; !ok {
+			skipped = append(skipped, s)
+		}
+	}
+	cs.Skipped = skipped
+
+	var claimed []schema.Record
+	for _, d := range desired {
+		isSkipped := indexOf(cs.Skipped, func(s schema.Record) bool {
+			return KeyOf(d) == KeyOf(s) && SameValue(d, s)
+		}) >= 0
+		if _, conflict := conflicts[KeyOf(d)]; !isSkipped && !conflict {
+			claimed = append(claimed, Claim(d))
+		}
+	}
+	// owned records of conflicting sets are kept as they are
+	var kept []schema.Record
+	for _, a := range owned {
+		if _, conflict := conflicts[KeyOf(a)]; !conflict {
+			kept = append(kept, a)
+		}
+	}
+	return claimed, kept
+}
+
+// equal compares the records, including the comment and tags with ownership
+// or in exact mode
+func (p Planner) equal(a, b schema.Record) bool {
+	return Equal(a, b) && (!p.Ownership && !p.Exact || a.Comment == b.Comment && SameTags(a.Tags, b.Tags))
+}
+
+// SameTags reports whether both lists hold the same tags, in any order
+func SameTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// planSet plans the changes of a single record set
+func (p Planner) planSet(desired, actual []schema.Record) []Change {
+	desired = unique(desired)
+	actual = append([]schema.Record(nil), actual...)
+	sortByValue(desired)
+	sortByValue(actual)
+
+	var changes []Change
+	update := func(d, a schema.Record) {
+		d.ID = a.ID
+		changes = append(changes, Change{Action: Update, Old: &a, New: &d})
+	}
+
+	// pass 1: identical records need no change
+	var pending []schema.Record
+	for _, d := range desired {
+		if i := indexOf(actual, func(a schema.Record) bool { return p.equal(a, d) }); i >= 0 {
+			actual = append(actual[:i], actual[i+1:]...)
+			continue
+		}
+		pending = append(pending, d)
+	}
+
+	// pass 2: same value with different settings is updated in place
+	desired, pending = pending, nil
+	for _, d := range desired {
+		if i := indexOf(actual, func(a schema.Record) bool { return SameValue(a, d) }); i >= 0 {
+			update(d, actual[i])
+			actual = append(actual[:i], actual[i+1:]...)
+			continue
+		}
+		pending = append(pending, d)
+	}
+
+	// pass 3: reuse the remaining actual records for the remaining values,
+	// then create or delete the rest
+	for i, d := range pending {
+		if i < len(actual) {
+			update(d, actual[i])
+			continue
+		}
+		d := d
+		changes = append(changes, Change{Action: Create, New: &d})
+	}
+	for i := len(pending); i < len(actual); i++ {
+		a := actual[i]
+		changes = append(changes, Change{Action: Delete, Old: &a})
+	}
+	return changes
+}
+
+// sortKeys sorts the record set keys by name and type
+func sortKeys(keys []Key) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Name != keys[j].Name {
+			return keys[i].Name < keys[j].Name
+		}
+		return keys[i].Type < keys[j].Type
+	})
+}
+
+// unique drops records which are identical to an earlier one
+func unique(records []schema.Record) []schema.Record {
+	var result []schema.Record
+	for _, r := range records {
+		if indexOf(result, func(u schema.Record) bool { return Equal(u, r) }) < 0 {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// sortByValue sorts the records by value to make the plan deterministic
+func sortByValue(records []schema.Record) {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Value() < records[j].Value()
+	})
+}
+
+// indexOf returns the index of the first record matching or -1
+func indexOf(records []schema.Record, match func(schema.Record) bool) int {
+	for i, r := range records {
+		if match(r) {
+			return i
+		}
+	}
+	return -1
+}
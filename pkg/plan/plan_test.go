@@ -0,0 +1,258 @@
+package plan
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/mrinjamul/flareship/pkg/schema"
+)
+
+// record returns a record with automatic TTL
+func record(recordType, name, content string) schema.Record {
+	return schema.Record{Type: recordType, Name: name, Content: content, TTL: schema.TTLAuto}
+}
+
+// remote returns a remote record with an ID, owned by flareship if owned is set
+func remote(id, recordType, name, content string, owned bool) schema.Record {
+	r := record(recordType, name, content)
+	r.ID = id
+	if owned {
+		r = Claim(r)
+	}
+	return r
+}
+
+// summary describes the changes as sorted "action type name old>new" lines
+func summary(cs ChangeSet) []string {
+	lines := []string{}
+	for _, c := range cs.Changes {
+		r := c.Record()
+		line := fmt.Sprintf("%s %s %s ", c.Action, r.Type, r.Name)
+		if c.Old != nil {
+			line += c.Old.Value()
+		}
+		line += ">"
+		if c.New != nil {
+			line += c.New.Value()
+			if c.Old != nil && c.New.ID != c.Old.ID {
+				line += " (wrong id)"
+			}
+		}
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// values returns the values of the records
+func values(records []schema.Record) []string {
+	var result []string
+	for _, r := range records {
+		result = append(result, r.Value())
+	}
+	return result
+}
+
+func TestPlan(t *testing.T) {
+	proxied := record("A", "www", "1.1.1.1")
+	proxied.Proxied = true
+
+	tests := []struct {
+		name    string
+		desired []schema.Record
+		actual  []schema.Record
+		want    []string
+	}{
+		{
+			name:    "unchanged",
+			desired: []schema.Record{record("A", "www", "1.1.1.1")},
+			actual:  []schema.Record{remote("1", "A", "www", "1.1.1.1", false)},
+			want:    []string{},
+		},
+		{
+			name:    "names are case insensitive",
+			desired: []schema.Record{record("A", "WWW", "1.1.1.1")},
+			actual:  []schema.Record{remote("1", "a", "www", "1.1.1.1", false)},
+			want:    []string{},
+		},
+		{
+			name:    "settings are updated in place",
+			desired: []schema.Record{proxied},
+			actual:  []schema.Record{remote("1", "A", "www", "1.1.1.1", false)},
+			want:    []string{"update A www 1.1.1.1>1.1.1.1"},
+		},
+		{
+			name: "round robin value replaced",
+			desired: []schema.Record{
+				record("A", "www", "1.1.1.1"), record("A", "www", "2.2.2.2"), record("A", "www", "3.3.3.3"),
+			},
+			actual: []schema.Record{
+				remote("2", "A", "www", "2.2.2.2", false), remote("3", "A", "www", "3.3.3.3", false), remote("4", "A", "www", "4.4.4.4", false),
+			},
+			want: []string{"update A www 4.4.4.4>1.1.1.1"},
+		},
+		{
+			name:    "record set grows",
+			desired: []schema.Record{record("A", "www", "1.1.1.1"), record("A", "www", "2.2.2.2")},
+			actual:  []schema.Record{remote("1", "A", "www", "1.1.1.1", false)},
+			want:    []string{"create A www >2.2.2.2"},
+		},
+		{
+			name:    "record set shrinks",
+			desired: []schema.Record{record("A", "www", "1.1.1.1")},
+			actual:  []schema.Record{remote("1", "A", "www", "1.1.1.1", false), remote("2", "A", "www", "2.2.2.2", false)},
+			want:    []string{"delete A www 2.2.2.2>"},
+		},
+		{
+			name:    "duplicate desired records are created once",
+			desired: []schema.Record{record("TXT", "@", "a"), record("TXT", "@", "a"), record("TXT", "@", "b")},
+			actual:  []schema.Record{remote("1", "TXT", "@", "b", false)},
+			want:    []string{"create TXT @ >a"},
+		},
+		{
+			name:    "record sets are independent",
+			desired: []schema.Record{record("A", "www", "1.1.1.1"), record("TXT", "www", "1.1.1.1")},
+			actual:  []schema.Record{remote("1", "A", "www", "1.1.1.1", false), remote("2", "CNAME", "api", "example.com", false)},
+			want:    []string{"create TXT www >1.1.1.1", "delete CNAME api example.com>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := summary(Plan(tt.desired, tt.actual))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got changes %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanOwnership(t *testing.T) {
+	tests := []struct {
+		name      string
+		adopt     bool
+		desired   []schema.Record
+		actual    []schema.Record
+		want      []string
+		skipped   []string
+		conflicts []string
+		unmanaged int
+	}{
+		{
+			name:    "owned records are unchanged",
+			desired: []schema.Record{record("A", "www", "1.1.1.1")},
+			actual:  []schema.Record{remote("1", "A", "www", "1.1.1.1", true)},
+			want:    []string{},
+		},
+		{
+			name:      "only owned records are deleted",
+			actual:    []schema.Record{remote("1", "A", "old", "1.1.1.1", true), remote("2", "A", "other", "2.2.2.2", false)},
+			want:      []string{"delete A old 1.1.1.1>"},
+			unmanaged: 1,
+		},
+		{
+			name:    "owned records are claimed on update",
+			desired: []schema.Record{record("A", "www", "1.1.1.1")},
+			actual:  []schema.Record{remote("1", "A", "www", "2.2.2.2", true)},
+			want:    []string{"update A www 2.2.2.2>1.1.1.1"},
+		},
+		{
+			name:      "unowned record with the same value is skipped",
+			desired:   []schema.Record{record("A", "www", "1.1.1.1"), record("A", "www", "2.2.2.2")},
+			actual:    []schema.Record{remote("1", "A", "www", "1.1.1.1", false)},
+			want:      []string{"create A www >2.2.2.2"},
+			skipped:   []string{"1.1.1.1"},
+			unmanaged: 1,
+		},
+		{
+			name:    "unowned record with another value conflicts with its set",
+			desired: []schema.Record{record("A", "@", "1.1.1.1"), record("A", "@", "3.3.3.3"), record("A", "www", "1.1.1.1")},
+			actual: []schema.Record{
+				remote("1", "A", "@", "2.2.2.2", false), remote("2", "A", "@", "3.3.3.3", true),
+			},
+			want:      []string{"create A www >1.1.1.1"},
+			conflicts: []string{"A @ [1.1.1.1 3.3.3.3] [2.2.2.2]"},
+			unmanaged: 1,
+		},
+		{
+			name:    "unowned record with a desired value is part of the conflict",
+			desired: []schema.Record{record("TXT", "@", "a"), record("TXT", "@", "b")},
+			actual: []schema.Record{
+				remote("1", "TXT", "@", "a", false), remote("2", "TXT", "@", "c", false),
+			},
+			want:      []string{},
+			conflicts: []string{"TXT @ [a b] [c a]"},
+			unmanaged: 2,
+		},
+		{
+			name:    "adopt claims records with the same value",
+			adopt:   true,
+			desired: []schema.Record{record("A", "www", "1.1.1.1")},
+			actual:  []schema.Record{remote("1", "A", "www", "1.1.1.1", false)},
+			want:    []string{"update A www 1.1.1.1>1.1.1.1"},
+		},
+		{
+			name:    "adopt claims records by name and type",
+			adopt:   true,
+			desired: []schema.Record{record("CNAME", "www", "other.example.com")},
+			actual:  []schema.Record{remote("1", "CNAME", "www", "example.com", false)},
+			want:    []string{"update CNAME www example.com>other.example.com"},
+		},
+		{
+			name:      "adopt leaves other record sets alone",
+			adopt:     true,
+			desired:   []schema.Record{record("A", "www", "1.1.1.1")},
+			actual:    []schema.Record{remote("1", "A", "www", "1.1.1.1", true), remote("2", "TXT", "www", "x", false)},
+			want:      []string{},
+			unmanaged: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := Planner{Ownership: true, Adopt: tt.adopt}.Plan(tt.desired, tt.actual)
+			if got := summary(cs); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got changes %q, want %q", got, tt.want)
+			}
+			if got := values(cs.Skipped); !reflect.DeepEqual(got, tt.skipped) {
+				t.Fatalf("got skipped %q, want %q", got, tt.skipped)
+			}
+			var conflicts []string
+			for _, c := range cs.Conflicts {
+				conflicts = append(conflicts, fmt.Sprintf("%s %s %v %v", c.Key().Type, c.Key().Name, values(c.Desired), values(c.Remote)))
+			}
+			if !reflect.DeepEqual(conflicts, tt.conflicts) {
+				t.Fatalf("got conflicts %q, want %q", conflicts, tt.conflicts)
+			}
+			if len(cs.Unmanaged) != tt.unmanaged {
+				t.Fatalf("got %d unmanaged records, want %d", len(cs.Unmanaged), tt.unmanaged)
+			}
+			for _, c := range cs.Changes {
+				if c.New != nil && !Owned(*c.New) {
+					t.Fatalf("%s of %s %s does not claim the record", c.Action, c.New.Type, c.New.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := record("MX", "@", "mx.example.net")
+	priority := uint16(10)
+	old.Priority = &priority
+	old.TTL = 300
+	updated := old
+	updated.TTL = 600
+	updated.Tags = []string{"b", "a"}
+
+	want := []FieldChange{
+		{Field: "ttl", Old: "300", New: "600"},
+		{Field: "tags", Old: "", New: "a, b"},
+	}
+	if got := Diff(old, updated); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got := Diff(old, old); len(got) != 0 {
+		t.Fatalf("got %v for identical records, want none", got)
+	}
+}
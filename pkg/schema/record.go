@@ -0,0 +1,248 @@
+package schema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// TTLAuto lets cloudflare choose the TTL, it is the only TTL of proxied records
+	TTLAuto = 1
+	// MinTTL and MaxTTL bound the TTL of records which are not automatic
+	MinTTL = 60
+	MaxTTL = 86400
+	// MaxCommentLength is the longest record comment accepted on all plans
+	MaxCommentLength = 100
+)
+
+// RecordTypes lists all record types supported by flareship
+var RecordTypes = []string{"A", "AAAA", "CAA", "CNAME", "HTTPS", "LOC", "MX", "NS", "SRV", "SSHFP", "SVCB", "TLSA", "TXT", "URI"}
+
+// RecordData is the structured value of CAA, HTTPS, LOC, SRV, SSHFP, SVCB,
+// TLSA and URI records. Only the fields of the record's type are set.
+// Numeric fields are pointers because zero is a valid value.
+type RecordData struct {
+	// SRV, HTTPS and SVCB
+	Priority *uint16 `json:"priority,omitempty"`
+	// SRV and URI
+	Weight *uint16 `json:"weight,omitempty"`
+	// SRV
+	Port *uint16 `json:"port,omitempty"`
+	// SRV, HTTPS, SVCB and URI
+	Target string `json:"target,omitempty"`
+
+	// CAA
+	Flags *uint8 `json:"flags,omitempty"`
+	Tag   string `json:"tag,omitempty"`
+	// CAA, HTTPS and SVCB
+	Value string `json:"value,omitempty"`
+
+	// TLSA
+	Usage        *uint8 `json:"usage,omitempty"`
+	Selector     *uint8 `json:"selector,omitempty"`
+	MatchingType *uint8 `json:"matching_type,omitempty"`
+	Certificate  string `json:"certificate,omitempty"`
+
+	// SSHFP
+	Algorithm   *uint8 `json:"algorithm,omitempty"`
+	Type        *uint8 `json:"type,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	// LOC
+	LatDegrees    *uint8   `json:"lat_degrees,omitempty"`
+	LatMinutes    *uint8   `json:"lat_minutes,omitempty"`
+	LatSeconds    *float64 `json:"lat_seconds,omitempty"`
+	LatDirection  string   `json:"lat_direction,omitempty"`
+	LongDegrees   *uint8   `json:"long_degrees,omitempty"`
+	LongMinutes   *uint8   `json:"long_minutes,omitempty"`
+	LongSeconds   *float64 `json:"long_seconds,omitempty"`
+	LongDirection string   `json:"long_direction,omitempty"`
+	Altitude      *float64 `json:"altitude,omitempty"`
+	Size          *float64 `json:"size,omitempty"`
+	PrecisionHorz *float64 `json:"precision_horz,omitempty"`
+	PrecisionVert *float64 `json:"precision_vert,omitempty"`
+}
+
+// Uint8 returns a pointer to v, for building RecordData
+func Uint8(v uint8) *uint8 {
+	return &v
+}
+
+// Uint16 returns a pointer to v, for building RecordData and priorities
+func Uint16(v uint16) *uint16 {
+	return &v
+}
+
+// Float64 returns a pointer to v, for building RecordData
+func Float64(v float64) *float64 {
+	return &v
+}
+
+// HasData reports whether records of the type carry their value in Data
+func HasData(recordType string) bool {
+	switch recordType {
+	case "CAA", "HTTPS", "LOC", "SRV", "SSHFP", "SVCB", "TLSA", "URI":
+		return true
+	}
+	return false
+}
+
+// HasPriority reports whether records of the type have a top-level priority
+func HasPriority(recordType string) bool {
+	return recordType == "MX" || recordType == "URI"
+}
+
+// Proxiable reports whether records of the type can be proxied by cloudflare
+func Proxiable(recordType string) bool {
+	return recordType == "A" || recordType == "AAAA" || recordType == "CNAME"
+}
+
+// Validate checks that the record has the fields required by its type
+func (r Record) Validate() error {
+	if r.Type == "" {
+		return fmt.Errorf("record type cannot be empty")
+	}
+	if r.Name == "" {
+		return fmt.Errorf("record name cannot be empty")
+	}
+	if HasPriority(r.Type) && r.Priority == nil {
+		return fmt.Errorf("%s record %s requires 'priority'", r.Type, r.Name)
+	}
+	if r.Proxied && !Proxiable(r.Type) {
+		return fmt.Errorf("%s record %s cannot be proxied", r.Type, r.Name)
+	}
+	if r.Proxied && r.TTL > TTLAuto {
+		return fmt.Errorf("%s record %s is proxied, its TTL must be auto (1)", r.Type, r.Name)
+	}
+	if err := ValidateTTL(r.TTL); err != nil {
+		return fmt.Errorf("%s record %s: %w", r.Type, r.Name, err)
+	}
+	for _, tag := range r.Tags {
+		if strings.TrimSpace(tag) == "" {
+			return fmt.Errorf("%s record %s has an empty tag", r.Type, r.Name)
+		}
+	}
+	if !HasData(r.Type) {
+		if r.Content == "" {
+			return fmt.Errorf("%s record %s requires 'content'", r.Type, r.Name)
+		}
+		return nil
+	}
+
+	d := r.Data
+	if d == nil {
+		return fmt.Errorf("%s record %s requires 'data'", r.Type, r.Name)
+	}
+	var missing []string
+	require := func(field string, ok bool) {
+		if !ok {
+			missing = append(missing, field)
+		}
+	}
+	switch r.Type {
+	case "SRV":
+		require("priority", d.Priority != nil)
+		require("weight", d.Weight != nil)
+		require("port", d.Port != nil)
+		require("target", d.Target != "")
+	case "CAA":
+		require("flags", d.Flags != nil)
+		require("tag", d.Tag != "")
+		require("value", d.Value != "")
+	case "TLSA":
+		require("usage", d.Usage != nil)
+		require("selector", d.Selector != nil)
+		require("matching_type", d.MatchingType != nil)
+		require("certificate", d.Certificate != "")
+	case "SSHFP":
+		require("algorithm", d.Algorithm != nil)
+		require("type", d.Type != nil)
+		require("fingerprint", d.Fingerprint != "")
+	case "HTTPS", "SVCB":
+		require("priority", d.Priority != nil)
+		require("target", d.Target != "")
+	case "URI":
+		require("weight", d.Weight != nil)
+		require("target", d.Target != "")
+	case "LOC":
+		require("lat_degrees", d.LatDegrees != nil)
+		require("lat_direction", d.LatDirection != "")
+		require("long_degrees", d.LongDegrees != nil)
+		require("long_direction", d.LongDirection != "")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s record %s requires data fields: %s", r.Type, r.Name, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// ValidateTTL checks that the TTL is unset (0), auto (1) or between MinTTL and MaxTTL
+func ValidateTTL(ttl uint) error {
+	if ttl > TTLAuto && (ttl < MinTTL || ttl > MaxTTL) {
+		return fmt.Errorf("TTL must be 1 (auto) or between %d and %d, got %d", MinTTL, MaxTTL, ttl)
+	}
+	return nil
+}
+
+// Value returns the value of the record in zone file notation, without the
+// top-level priority. It is the content for types without structured data.
+func (r Record) Value() string {
+	d := r.Data
+	if d == nil || !HasData(r.Type) {
+		return r.Content
+	}
+	switch r.Type {
+	case "SRV":
+		return join(u16(d.Priority), u16(d.Weight), u16(d.Port), d.Target)
+	case "CAA":
+		return join(u8(d.Flags), d.Tag, strconv.Quote(d.Value))
+	case "TLSA":
+		return join(u8(d.Usage), u8(d.Selector), u8(d.MatchingType), d.Certificate)
+	case "SSHFP":
+		return join(u8(d.Algorithm), u8(d.Type), d.Fingerprint)
+	case "HTTPS", "SVCB":
+		return join(u16(d.Priority), d.Target, d.Value)
+	case "URI":
+		return join(u16(d.Weight), strconv.Quote(d.Target))
+	case "LOC":
+		return join(
+			u8(d.LatDegrees), u8(d.LatMinutes), f64(d.LatSeconds), d.LatDirection,
+			u8(d.LongDegrees), u8(d.LongMinutes), f64(d.LongSeconds), d.LongDirection,
+			f64(d.Altitude)+"m", f64(d.Size)+"m", f64(d.PrecisionHorz)+"m", f64(d.PrecisionVert)+"m",
+		)
+	}
+	return r.Content
+}
+
+// join joins the non-empty fields with spaces
+func join(fields ...string) string {
+	var parts []string
+	for _, f := range fields {
+		if f != "" {
+			parts = append(parts, f)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func u8(v *uint8) string {
+	if v == nil {
+		return "0"
+	}
+	return strconv.Itoa(int(*v))
+}
+
+func u16(v *uint16) string {
+	if v == nil {
+		return "0"
+	}
+	return strconv.Itoa(int(*v))
+}
+
+func f64(v *float64) string {
+	if v == nil {
+		return "0"
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
@@ -1,6 +1,9 @@
 package schema
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // Record is the struct for the record
 type Record struct {
@@ -11,6 +14,16 @@ type Record struct {
 	Proxiable bool   `json:"proxiable,omitempty"`
 	Proxied   bool   `json:"proxied,omitempty"`
 	TTL       uint   `json:"ttl,omitempty"`
+	// Priority is the priority of MX and URI records
+	Priority *uint16 `json:"priority,omitempty"`
+	// Data is the structured value of CAA, SRV, TLSA and similar records
+	Data    *RecordData `json:"data,omitempty"`
+	Comment string      `json:"comment,omitempty"`
+	// Tags are custom "name:value" labels, they have no effect on DNS
+	Tags []string `json:"tags,omitempty"`
+	// CreatedOn and ModifiedOn are set by the API and never sent to it
+	CreatedOn  string `json:"created_on,omitempty"`
+	ModifiedOn string `json:"modified_on,omitempty"`
 }
 
 // Owner is the struct for the owner schema
@@ -29,17 +42,21 @@ type Records struct {
 
 // Result is the record which is returned from the API
 type Result struct {
-	ID         string `json:"id"`
-	ZoneID     string `json:"zone_id"`
-	ZoneName   string `json:"zone_name"`
-	Name       string `json:"name"`
-	Type       string `json:"type"`
-	Content    string `json:"content"`
-	Proxiable  bool   `json:"proxiable"`
-	Proxied    bool   `json:"proxied"`
-	TTL        uint   `json:"ttl"`
-	CreatedOn  string `json:"created_on"`
-	ModifiedOn string `json:"modified_on"`
+	ID         string      `json:"id"`
+	ZoneID     string      `json:"zone_id"`
+	ZoneName   string      `json:"zone_name"`
+	Name       string      `json:"name"`
+	Type       string      `json:"type"`
+	Content    string      `json:"content"`
+	Proxiable  bool        `json:"proxiable"`
+	Proxied    bool        `json:"proxied"`
+	TTL        uint        `json:"ttl"`
+	Priority   *uint16     `json:"priority,omitempty"`
+	Data       *RecordData `json:"data,omitempty"`
+	Comment    string      `json:"comment"`
+	Tags       []string    `json:"tags"`
+	CreatedOn  string      `json:"created_on"`
+	ModifiedOn string      `json:"modified_on"`
 }
 
 // ResultInfo is the status of the request
@@ -51,17 +68,17 @@ type ResultInfo struct {
 	TotalPages uint `json:"total_pages"`
 }
 
-// ErrorChain is the error chain
+// ErrorChain is an underlying cause of an API error
 type ErrorChain struct {
-	Code uint   `json:"code"`
-	Type string `json:"type"`
+	Code    uint   `json:"code"`
+	Message string `json:"message"`
 }
 
 // Error is the error struct
 type Errors struct {
-	Code       uint       `json:"code"`
-	Message    string     `json:"message"`
-	ErrorChain ErrorChain `json:"error_chain"`
+	Code       uint         `json:"code"`
+	Message    string       `json:"message"`
+	ErrorChain []ErrorChain `json:"error_chain,omitempty"`
 }
 
 // CFResponse is the response struct we get from the API using GET method
@@ -89,8 +106,38 @@ type DelResult struct {
 
 // DelResponse is the response struct we get from the API using DELETE method
 type DelResponse struct {
-	Result DelResult `json:"result"`
-	Errors []Errors  `json:"errors"`
+	Success bool      `json:"success"`
+	Result  DelResult `json:"result"`
+	Errors  []Errors  `json:"errors"`
+}
+
+// BatchRequest is the body of the batch DNS records endpoint. Cloudflare
+// applies it in a single transaction in the order deletes, patches, puts, posts.
+type BatchRequest struct {
+	Deletes []Record `json:"deletes,omitempty"`
+	Patches []Record `json:"patches,omitempty"`
+	Puts    []Record `json:"puts,omitempty"`
+	Posts   []Record `json:"posts,omitempty"`
+}
+
+// Len returns the total number of changes in the batch
+func (b BatchRequest) Len() int {
+	return len(b.Deletes) + len(b.Patches) + len(b.Puts) + len(b.Posts)
+}
+
+// BatchResult holds the records affected by a batch request
+type BatchResult struct {
+	Deletes []Result `json:"deletes"`
+	Patches []Result `json:"patches"`
+	Puts    []Result `json:"puts"`
+	Posts   []Result `json:"posts"`
+}
+
+// BatchResponse is the response struct we get from the batch endpoint
+type BatchResponse struct {
+	Success bool        `json:"success"`
+	Errors  []Errors    `json:"errors"`
+	Result  BatchResult `json:"result"`
 }
 
 // DomainConfig represents config for a single domain
@@ -101,11 +148,46 @@ type DomainConfig struct {
 	RecordFile     string   `json:"record_file"`
 	RestrictedFile string   `json:"restricted_file,omitempty"`
 	RecordTypes    []string `json:"record_type,omitempty"`
+	// DefaultTTL is the TTL of records without one, 1 (auto) if unset
+	DefaultTTL uint `json:"default_ttl,omitempty"`
+	// Tags are added to every record of the domain. "{owner}" and "{repo}"
+	// are replaced by the metadata of the record, tags left empty are dropped.
+	Tags []string `json:"tags,omitempty"`
+}
+
+// APIConfig tunes the cloudflare api client, unset fields use the defaults
+type APIConfig struct {
+	// BaseURL overrides the api root, e.g. a local `flareship dev-server`
+	BaseURL string `json:"base_url,omitempty"`
+	// MaxRetries is the number of retries of a failed idempotent request, 0 disables retries
+	MaxRetries *int `json:"max_retries,omitempty"`
+	// RetryWaitMin is the base delay of the exponential backoff, e.g. "500ms"
+	RetryWaitMin string `json:"retry_wait_min,omitempty"`
+	// RetryWaitMax caps the delay between two attempts, e.g. "30s"
+	RetryWaitMax string `json:"retry_wait_max,omitempty"`
+	// RateLimit is the number of requests allowed per token and window, negative disables throttling
+	RateLimit int `json:"rate_limit,omitempty"`
+	// RateLimitWindow is the window of RateLimit, e.g. "5m"
+	RateLimitWindow string `json:"rate_limit_window,omitempty"`
+	// BatchLimit is the maximum number of changes sent in one batch request
+	BatchLimit int `json:"batch_limit,omitempty"`
+}
+
+// SnapshotConfig controls the snapshots taken before sync changes records
+type SnapshotConfig struct {
+	// Dir is where snapshots are written, ".flareship/snapshots" by default
+	Dir string `json:"dir,omitempty"`
+	// Keep is the number of snapshots kept per domain, 20 by default
+	Keep int `json:"keep,omitempty"`
+	// Disabled turns snapshots off
+	Disabled bool `json:"disabled,omitempty"`
 }
 
 // AppConfig represents the full configuration (supports multi-domain in future)
 type AppConfig struct {
-	Domains []DomainConfig `json:"domains"`
+	Domains   []DomainConfig  `json:"domains"`
+	API       *APIConfig      `json:"api,omitempty"`
+	Snapshots *SnapshotConfig `json:"snapshots,omitempty"`
 }
 
 // validate ensures all required fields are present
@@ -120,6 +202,41 @@ func (c *AppConfig) Validate() error {
 		if len(domain.RecordTypes) == 0 {
 			return fmt.Errorf("domain[%d] must have at least one 'record_type'", i)
 		}
+		if err := ValidateTTL(domain.DefaultTTL); err != nil {
+			return fmt.Errorf("domain[%d] 'default_ttl': %w", i, err)
+		}
+	}
+	if c.API != nil {
+		if err := c.API.Validate(); err != nil {
+			return fmt.Errorf("api: %w", err)
+		}
+	}
+	if c.Snapshots != nil && c.Snapshots.Keep < 0 {
+		return fmt.Errorf("snapshots: 'keep' cannot be negative")
+	}
+	return nil
+}
+
+// Validate ensures the durations can be parsed and values are in range
+func (c *APIConfig) Validate() error {
+	if c.MaxRetries != nil && *c.MaxRetries < 0 {
+		return fmt.Errorf("'max_retries' cannot be negative")
+	}
+	if c.BatchLimit < 0 {
+		return fmt.Errorf("'batch_limit' cannot be negative")
+	}
+	durations := map[string]string{
+		"retry_wait_min":    c.RetryWaitMin,
+		"retry_wait_max":    c.RetryWaitMax,
+		"rate_limit_window": c.RateLimitWindow,
+	}
+	for name, value := range durations {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			return fmt.Errorf("'%s' must be a positive duration, got %q", name, value)
+		}
 	}
 	return nil
 }
@@ -0,0 +1,510 @@
+// Package zonefile reads and writes RFC 1035 master files ("BIND zone files")
+// as flareship records.
+package zonefile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mrinjamul/flareship/pkg/schema"
+)
+
+// Zone is a parsed zone file. Record names are fully qualified without the
+// trailing dot, like the names returned by the cloudflare api.
+type Zone struct {
+	// Origin is the origin given to Parse, or the first $ORIGIN of the file
+	Origin string
+	// TTL is the $TTL of the file, 0 if unset
+	TTL uint
+	// Records have no TTL if they use the $TTL of the file
+	Records []schema.Record
+	// Skipped lists the records of unsupported types, e.g. "example.com SOA"
+	Skipped []string
+
+	// current is the origin of relative names, changed by $ORIGIN
+	current string
+}
+
+// token is a word or a quoted string of a zone file
+type token struct {
+	text   string
+	quoted bool
+}
+
+// line is a logical line, parentheses joined
+type line struct {
+	number int
+	// blank is set when the line starts with a space, the owner is omitted
+	blank  bool
+	tokens []token
+}
+
+// Parse parses a zone file. origin is used for relative names until an
+// $ORIGIN directive, it can be empty if all names are absolute.
+func Parse(r io.Reader, origin string) (*Zone, error) {
+	lines, err := scan(r)
+	if err != nil {
+		return nil, err
+	}
+	zone := &Zone{Origin: strings.ToLower(trimDot(origin))}
+	zone.current = zone.Origin
+	var owner string
+	var lastTTL uint
+	for _, l := range lines {
+		tokens := l.tokens
+		if !l.blank && strings.HasPrefix(tokens[0].text, "$") && !tokens[0].quoted {
+			if err := zone.directive(tokens); err != nil {
+				return nil, fmt.Errorf("line %d: %w", l.number, err)
+			}
+			continue
+		}
+
+		if !l.blank {
+			name, err := zone.absolute(tokens[0].text)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", l.number, err)
+			}
+			owner = name
+			tokens = tokens[1:]
+		} else if owner == "" {
+			return nil, fmt.Errorf("line %d: record without owner name", l.number)
+		}
+
+		// TTL and class are optional and in any order
+		var ttl uint
+		var explicit bool
+		for len(tokens) > 0 && !tokens[0].quoted {
+			if isClass(tokens[0].text) {
+				tokens = tokens[1:]
+				continue
+			}
+			if value, ok := parseTTL(tokens[0].text); ok {
+				ttl, explicit = value, true
+				tokens = tokens[1:]
+				continue
+			}
+			break
+		}
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("line %d: missing record type", l.number)
+		}
+		if explicit {
+			lastTTL = ttl
+		}
+
+		recordType := strings.ToUpper(tokens[0].text)
+		if !supported(recordType) {
+			zone.Skipped = append(zone.Skipped, owner+" "+recordType)
+			continue
+		}
+		record, err := zone.record(owner, recordType, tokens[1:])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s record %s: %w", l.number, recordType, owner, err)
+		}
+		switch {
+		case explicit:
+			record.TTL = ttl
+		case zone.TTL == 0:
+			// without $TTL the last TTL applies (RFC 1035)
+			record.TTL = lastTTL
+		}
+		zone.Records = append(zone.Records, record)
+	}
+	return zone, nil
+}
+
+// directive applies $ORIGIN and $TTL
+func (z *Zone) directive(tokens []token) error {
+	if len(tokens) < 2 {
+		return fmt.Errorf("%s requires a value", tokens[0].text)
+	}
+	switch strings.ToUpper(tokens[0].text) {
+	case "$ORIGIN":
+		name, err := z.absolute(tokens[1].text)
+		if err != nil {
+			return err
+		}
+		z.current = name
+		if z.Origin == "" {
+			z.Origin = name
+		}
+	case "$TTL":
+		ttl, ok := parseTTL(tokens[1].text)
+		if !ok {
+			return fmt.Errorf("invalid $TTL %q", tokens[1].text)
+		}
+		z.TTL = ttl
+	default:
+		return fmt.Errorf("unsupported directive %s", tokens[0].text)
+	}
+	return nil
+}
+
+// absolute returns the fully qualified name without the trailing dot
+func (z *Zone) absolute(name string) (string, error) {
+	switch {
+	case name == "@":
+		if z.current == "" {
+			return "", fmt.Errorf("@ used without origin")
+		}
+		return z.current, nil
+	case strings.HasSuffix(name, "."):
+		return strings.ToLower(trimDot(name)), nil
+	case z.current == "":
+		return "", fmt.Errorf("relative name %s used without origin", name)
+	}
+	return strings.ToLower(name + "." + z.current), nil
+}
+
+// target returns a domain name of the rdata, "." stays the root
+func (z *Zone) target(name string) (string, error) {
+	if name == "." {
+		return name, nil
+	}
+	return z.absolute(name)
+}
+
+// record builds the record from its rdata
+func (z *Zone) record(owner, recordType string, rdata []token) (schema.Record, error) {
+	record := schema.Record{Type: recordType, Name: owner}
+	fields := make([]string, len(rdata))
+	for i, t := range rdata {
+		fields[i] = t.text
+	}
+	want := func(n int) error {
+		if len(fields) < n {
+			return fmt.Errorf("expected %d fields, got %d", n, len(fields))
+		}
+		return nil
+	}
+
+	var err error
+	switch recordType {
+	case "A", "AAAA":
+		if err = want(1); err == nil {
+			record.Content = fields[0]
+		}
+	case "CNAME", "NS":
+		if err = want(1); err == nil {
+			record.Content, err = z.target(fields[0])
+		}
+	case "TXT":
+		if err = want(1); err == nil {
+			record.Content = strings.Join(fields, "")
+		}
+	case "MX":
+		if err = want(2); err != nil {
+			break
+		}
+		if record.Priority, err = parseUint16(fields[0]); err == nil {
+			record.Content, err = z.target(fields[1])
+		}
+	case "SRV":
+		if err = want(4); err != nil {
+			break
+		}
+		d := &schema.RecordData{}
+		record.Data = d
+		if d.Priority, err = parseUint16(fields[0]); err != nil {
+			break
+		}
+		if d.Weight, err = parseUint16(fields[1]); err != nil {
+			break
+		}
+		if d.Port, err = parseUint16(fields[2]); err != nil {
+			break
+		}
+		d.Target, err = z.target(fields[3])
+	case "CAA":
+		if err = want(3); err != nil {
+			break
+		}
+		d := &schema.RecordData{Tag: fields[1], Value: strings.Join(fields[2:], "")}
+		record.Data = d
+		d.Flags, err = parseUint8(fields[0])
+	case "TLSA":
+		if err = want(4); err != nil {
+			break
+		}
+		d := &schema.RecordData{Certificate: strings.Join(fields[3:], "")}
+		record.Data = d
+		if d.Usage, err = parseUint8(fields[0]); err != nil {
+			break
+		}
+		if d.Selector, err = parseUint8(fields[1]); err != nil {
+			break
+		}
+		d.MatchingType, err = parseUint8(fields[2])
+	case "SSHFP":
+		if err = want(3); err != nil {
+			break
+		}
+		d := &schema.RecordData{Fingerprint: strings.Join(fields[2:], "")}
+		record.Data = d
+		if d.Algorithm, err = parseUint8(fields[0]); err != nil {
+			break
+		}
+		d.Type, err = parseUint8(fields[1])
+	case "HTTPS", "SVCB":
+		if err = want(2); err != nil {
+			break
+		}
+		d := &schema.RecordData{Value: strings.Join(fields[2:], " ")}
+		record.Data = d
+		if d.Priority, err = parseUint16(fields[0]); err != nil {
+			break
+		}
+		d.Target, err = z.target(fields[1])
+	case "URI":
+		if err = want(3); err != nil {
+			break
+		}
+		d := &schema.RecordData{Target: fields[2]}
+		record.Data = d
+		if record.Priority, err = parseUint16(fields[0]); err != nil {
+			break
+		}
+		d.Weight, err = parseUint16(fields[1])
+	case "LOC":
+		record.Data, err = parseLOC(fields)
+	}
+	if err != nil {
+		return schema.Record{}, err
+	}
+	return record, nil
+}
+
+// parseLOC parses the RFC 1876 notation
+// "d1 [m1 [s1]] {N|S} d2 [m2 [s2]] {E|W} alt[m] [siz[m] [hp[m] [vp[m]]]]"
+func parseLOC(fields []string) (*schema.RecordData, error) {
+	d := &schema.RecordData{}
+	rest := fields
+	var err error
+	angle := func(directions string) (*uint8, *uint8, *float64, string, error) {
+		var parts []string
+		for len(rest) > 0 && !strings.Contains(directions, strings.ToUpper(rest[0])) {
+			parts = append(parts, rest[0])
+			rest = rest[1:]
+		}
+		if len(rest) == 0 || len(parts) == 0 || len(parts) > 3 {
+			return nil, nil, nil, "", fmt.Errorf("invalid LOC coordinate")
+		}
+		direction := strings.ToUpper(rest[0])
+		rest = rest[1:]
+		degrees, err := parseUint8(parts[0])
+		if err != nil {
+			return nil, nil, nil, "", err
+		}
+		minutes, seconds := schema.Uint8(0), schema.Float64(0)
+		if len(parts) > 1 {
+			if minutes, err = parseUint8(parts[1]); err != nil {
+				return nil, nil, nil, "", err
+			}
+		}
+		if len(parts) > 2 {
+			if seconds, err = parseMeters(parts[2]); err != nil {
+				return nil, nil, nil, "", err
+			}
+		}
+		return degrees, minutes, seconds, direction, nil
+	}
+	if d.LatDegrees, d.LatMinutes, d.LatSeconds, d.LatDirection, err = angle("NS"); err != nil {
+		return nil, err
+	}
+	if d.LongDegrees, d.LongMinutes, d.LongSeconds, d.LongDirection, err = angle("EW"); err != nil {
+		return nil, err
+	}
+	// altitude, size and precisions, with the RFC 1876 defaults
+	values := []*float64{schema.Float64(0), schema.Float64(1), schema.Float64(10000), schema.Float64(10)}
+	if len(rest) == 0 {
+		return nil, fmt.Errorf("LOC requires an altitude")
+	}
+	if len(rest) > len(values) {
+		return nil, fmt.Errorf("too many LOC fields")
+	}
+	for i, field := range rest {
+		if values[i], err = parseMeters(field); err != nil {
+			return nil, err
+		}
+	}
+	d.Altitude, d.Size, d.PrecisionHorz, d.PrecisionVert = values[0], values[1], values[2], values[3]
+	return d, nil
+}
+
+// scan splits the zone file into logical lines, dropping comments and
+// joining the lines inside parentheses
+func scan(r io.Reader) ([]line, error) {
+	var lines []line
+	var current *line
+	depth := 0
+	number := 0
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		number++
+		text := s.Text()
+		if depth == 0 {
+			if current != nil && len(current.tokens) > 0 {
+				lines = append(lines, *current)
+			}
+			current = &line{number: number, blank: text != "" && (text[0] == ' ' || text[0] == '\t')}
+		}
+		for i := 0; i < len(text); {
+			switch c := text[i]; {
+			case c == ';':
+				i = len(text)
+			case c == ' ' || c == '\t':
+				i++
+			case c == '(':
+				depth++
+				i++
+			case c == ')':
+				if depth == 0 {
+					return nil, fmt.Errorf("line %d: unbalanced )", number)
+				}
+				depth--
+				i++
+			case c == '"':
+				value, n, err := unquote(text[i:])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", number, err)
+				}
+				current.tokens = append(current.tokens, token{text: value, quoted: true})
+				i += n
+			default:
+				// quotes inside a word are kept, e.g. alpn="h2,h3"
+				start := i
+				for i < len(text) && !strings.ContainsRune(" \t;()", rune(text[i])) {
+					switch text[i] {
+					case '\\':
+						i++
+					case '"':
+						if end := strings.IndexByte(text[i+1:], '"'); end >= 0 {
+							i += end + 1
+						}
+					}
+					i++
+				}
+				current.tokens = append(current.tokens, token{text: text[start:i]})
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("line %d: unbalanced (", number)
+	}
+	if current != nil && len(current.tokens) > 0 {
+		lines = append(lines, *current)
+	}
+	return lines, nil
+}
+
+// unquote reads the quoted string at the start of s, it returns its value
+// and the number of bytes read
+func unquote(s string) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\\':
+			if i+3 < len(s) && isDigits(s[i+1:i+4]) {
+				n, _ := strconv.Atoi(s[i+1 : i+4])
+				b.WriteByte(byte(n))
+				i += 3
+			} else if i+1 < len(s) {
+				b.WriteByte(s[i+1])
+				i++
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated quoted string")
+}
+
+// parseTTL parses a TTL in seconds or with BIND units, e.g. "1h30m"
+func parseTTL(s string) (uint, bool) {
+	if s == "" || s[0] < '0' || s[0] > '9' {
+		return 0, false
+	}
+	var total, n uint64
+	for _, c := range strings.ToLower(s) {
+		if c >= '0' && c <= '9' {
+			n = n*10 + uint64(c-'0')
+			continue
+		}
+		unit, ok := map[rune]uint64{'s': 1, 'm': 60, 'h': 3600, 'd': 86400, 'w': 604800}[c]
+		if !ok {
+			return 0, false
+		}
+		total += n * unit
+		n = 0
+	}
+	return uint(total + n), true
+}
+
+// isClass reports whether the word is a DNS class
+func isClass(s string) bool {
+	switch strings.ToUpper(s) {
+	case "IN", "CH", "HS", "CS":
+		return true
+	}
+	return false
+}
+
+// supported reports whether flareship manages records of the type
+func supported(recordType string) bool {
+	for _, t := range schema.RecordTypes {
+		if t == recordType {
+			return true
+		}
+	}
+	return false
+}
+
+func parseUint8(s string) (*uint8, error) {
+	n, err := strconv.ParseUint(s, 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q", s)
+	}
+	return schema.Uint8(uint8(n)), nil
+}
+
+func parseUint16(s string) (*uint16, error) {
+	n, err := strconv.ParseUint(s, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q", s)
+	}
+	return schema.Uint16(uint16(n)), nil
+}
+
+// parseMeters parses a LOC distance, with an optional "m" suffix
+func parseMeters(s string) (*float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(s), "m"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q", s)
+	}
+	return schema.Float64(f), nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func trimDot(name string) string {
+	if name == "." {
+		return name
+	}
+	return strings.TrimSuffix(name, ".")
+}
@@ -0,0 +1,119 @@
+package zonefile
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mrinjamul/flareship/pkg/schema"
+)
+
+// AutoTTL is the TTL written for records with an automatic TTL, it is the
+// TTL cloudflare uses for them
+const AutoTTL = 300
+
+// maxString is the longest character string of a TXT record
+const maxString = 255
+
+// Write writes the records as a zone file of origin. The $TTL of the file is
+// defaultTTL, or AutoTTL if it is automatic. Records with that TTL or an
+// automatic one are written without TTL. Names must be fully qualified.
+func Write(w io.Writer, origin string, defaultTTL uint, records []schema.Record) error {
+	origin = trimDot(origin)
+	if defaultTTL <= schema.TTLAuto {
+		defaultTTL = AutoTTL
+	}
+	records = append([]schema.Record(nil), records...)
+	sort.SliceStable(records, func(i, j int) bool {
+		a, b := relative(records[i].Name, origin), relative(records[j].Name, origin)
+		if a != b {
+			return a < b
+		}
+		return records[i].Type < records[j].Type
+	})
+
+	if _, err := fmt.Fprintf(w, "$ORIGIN %s.\n$TTL %d\n", origin, defaultTTL); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	for _, r := range records {
+		ttl := ""
+		if r.TTL > schema.TTLAuto && r.TTL != defaultTTL {
+			ttl = fmt.Sprint(r.TTL)
+		}
+		fmt.Fprintf(tw, "%s\t%s\tIN\t%s\t%s\n", relative(r.Name, origin), ttl, r.Type, rdata(r))
+	}
+	return tw.Flush()
+}
+
+// rdata returns the value of the record in zone file notation
+func rdata(r schema.Record) string {
+	d := r.Data
+	if d == nil {
+		d = &schema.RecordData{}
+	}
+	var value string
+	switch r.Type {
+	case "CNAME", "NS", "MX":
+		value = fqdn(r.Content)
+	case "TXT":
+		value = quoteTXT(r.Content)
+	case "SRV":
+		value = fmt.Sprintf("%d %d %d %s", u16(d.Priority), u16(d.Weight), u16(d.Port), fqdn(d.Target))
+	case "HTTPS", "SVCB":
+		value = strings.TrimSpace(fmt.Sprintf("%d %s %s", u16(d.Priority), fqdn(d.Target), d.Value))
+	default:
+		value = r.Value()
+	}
+	if r.Priority != nil {
+		value = fmt.Sprintf("%d %s", *r.Priority, value)
+	}
+	return value
+}
+
+// relative returns the name relative to origin, "@" for the origin itself.
+// Names outside of origin are written fully qualified.
+func relative(name, origin string) string {
+	name = strings.ToLower(trimDot(name))
+	switch {
+	case name == origin:
+		return "@"
+	case strings.HasSuffix(name, "."+origin):
+		return strings.TrimSuffix(name, "."+origin)
+	}
+	return fqdn(name)
+}
+
+// fqdn adds the trailing dot to the name
+func fqdn(name string) string {
+	if name == "" || strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// quoteTXT quotes the TXT value, split into strings of at most 255 bytes
+func quoteTXT(content string) string {
+	var parts []string
+	for {
+		n := len(content)
+		if n > maxString {
+			n = maxString
+		}
+		chunk := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(content[:n])
+		parts = append(parts, `"`+chunk+`"`)
+		content = content[n:]
+		if content == "" {
+			return strings.Join(parts, " ")
+		}
+	}
+}
+
+func u16(v *uint16) uint16 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
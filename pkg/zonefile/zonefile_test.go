@@ -0,0 +1,124 @@
+package zonefile
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mrinjamul/flareship/pkg/schema"
+)
+
+func TestRoundTrip(t *testing.T) {
+	long := strings.Repeat("k", 300)
+	records := []schema.Record{
+		{Type: "A", Name: "example.com", Content: "192.0.2.1", TTL: schema.TTLAuto},
+		{Type: "A", Name: "example.com", Content: "192.0.2.2", TTL: schema.TTLAuto},
+		{Type: "AAAA", Name: "www.example.com", Content: "2001:db8::1", TTL: 3600},
+		{Type: "CNAME", Name: "*.dev.example.com", Content: "www.example.com", TTL: schema.TTLAuto},
+		{Type: "CNAME", Name: "cdn.example.com", Content: "cdn.example.net", TTL: schema.TTLAuto},
+		{Type: "MX", Name: "example.com", Content: "mx1.example.net", Priority: schema.Uint16(10), TTL: schema.TTLAuto},
+		{Type: "TXT", Name: "example.com", Content: `v=spf1 include:"x" -all`, TTL: schema.TTLAuto},
+		{Type: "TXT", Name: "dkim._domainkey.example.com", Content: long, TTL: 600},
+		{Type: "SRV", Name: "_sip._tcp.example.com", TTL: schema.TTLAuto, Data: &schema.RecordData{
+			Priority: schema.Uint16(0), Weight: schema.Uint16(5), Port: schema.Uint16(5060), Target: "sip.example.com",
+		}},
+		{Type: "CAA", Name: "example.com", TTL: schema.TTLAuto, Data: &schema.RecordData{
+			Flags: schema.Uint8(0), Tag: "issue", Value: "letsencrypt.org",
+		}},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, "example.com", 0, records); err != nil {
+		t.Fatal(err)
+	}
+	zone, err := Parse(&buf, "")
+	if err != nil {
+		t.Fatalf("%v\n%s", err, buf.String())
+	}
+	if zone.Origin != "example.com" || zone.TTL != AutoTTL {
+		t.Fatalf("got origin %q and $TTL %d, want example.com and %d", zone.Origin, zone.TTL, AutoTTL)
+	}
+	if len(zone.Records) != len(records) {
+		t.Fatalf("got %d records, want %d", len(zone.Records), len(records))
+	}
+
+	for _, want := range records {
+		// automatic TTLs are written as the $TTL and read back as unset
+		if want.TTL == schema.TTLAuto {
+			want.TTL = 0
+		}
+		var found bool
+		for _, got := range zone.Records {
+			if got.Type == want.Type && got.Name == want.Name && got.Value() == want.Value() {
+				found = true
+				if got.TTL != want.TTL || !reflect.DeepEqual(got.Priority, want.Priority) || !reflect.DeepEqual(got.Data, want.Data) {
+					t.Errorf("%s %s: got %+v, want %+v", want.Type, want.Name, got, want)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%s %s %s is missing after the round trip", want.Type, want.Name, want.Value())
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	const file = `$ORIGIN example.com.
+$TTL 1h
+@	IN SOA ns1.example.com. hostmaster.example.com. (
+		2024010101 ; serial
+		7200 3600 1209600 300 )
+	IN	MX	10 mx1.example.net.
+www	300	IN	A	192.0.2.1 ; web
+	IN	AAAA	2001:db8::1
+$ORIGIN dev.example.com.
+api		CNAME	www.example.com.
+other.example.org.	A	192.0.2.9
+`
+	zone, err := Parse(strings.NewReader(file), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zone.TTL != 3600 {
+		t.Errorf("got $TTL %d, want 3600", zone.TTL)
+	}
+	if want := []string{"example.com SOA"}; !reflect.DeepEqual(zone.Skipped, want) {
+		t.Errorf("got skipped %q, want %q", zone.Skipped, want)
+	}
+
+	want := []string{
+		"MX example.com 10 mx1.example.net 0",
+		"A www.example.com 192.0.2.1 300",
+		"AAAA www.example.com 2001:db8::1 0",
+		"CNAME api.dev.example.com www.example.com 0",
+		"A other.example.org 192.0.2.9 0",
+	}
+	var got []string
+	for _, r := range zone.Records {
+		line := r.Type + " " + r.Name + " "
+		if r.Priority != nil {
+			line += fmt.Sprint(*r.Priority) + " "
+		}
+		got = append(got, line+r.Value()+" "+fmt.Sprint(r.TTL))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got records\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"relative name without origin": "www A 192.0.2.1\n",
+		"missing owner":                "\tA 192.0.2.1\n",
+		"missing type":                 "www.example.com. 300\n",
+	}
+	for name, file := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse(strings.NewReader(file), ""); err == nil {
+				t.Fatalf("Parse succeeded, want an error")
+			}
+		})
+	}
+}